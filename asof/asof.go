@@ -0,0 +1,90 @@
+// Package asof evaluates database queries as if they were run at a point
+// in the past, so that old reports can be recomputed and audited
+package asof
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// setting is the gorm setting holding the as of time of a connection
+	setting = "tfarmer:as_of"
+	// callback is the name of the callback scoping queries
+	callback = "tfarmer:as_of"
+)
+
+// Apply returns a connection whose queries are evaluated as of the given
+// time. rows of the queried model created after it are excluded, and rows
+// soft deleted after it are included, by querying unscoped.
+func Apply(db *gorm.DB, at time.Time) *gorm.DB {
+	if db.Callback().Query().Get(callback) == nil {
+		db.Callback().Query().Before("gorm:query").Register(callback, scope)
+	}
+	if db.Callback().RowQuery().Get(callback) == nil {
+		db.Callback().RowQuery().Before("gorm:row_query").Register(callback, scope)
+	}
+	return db.Set(setting, at)
+}
+
+// Remove returns a connection whose queries are evaluated
+// as of now, for queries which handle history themselves
+func Remove(db *gorm.DB) *gorm.DB {
+	return db.Set(setting, time.Time{})
+}
+
+// Time returns the time queries of the connection are evaluated as of,
+// and whether the connection is evaluated as of a time other than now
+func Time(db *gorm.DB) (time.Time, bool) {
+	value, ok := db.Get(setting)
+	if !ok {
+		return time.Time{}, false
+	}
+	at := value.(time.Time)
+	return at, !at.IsZero()
+}
+
+// Now returns the time queries of the connection are evaluated as
+// of, which farmers should use in place of the current time
+func Now(db *gorm.DB) time.Time {
+	if at, ok := Time(db); ok {
+		return at
+	}
+	return time.Now()
+}
+
+// Live returns the condition selecting the rows of the given table which
+// existed at the time the connection is evaluated as of, along with its
+// arguments, for tables which are joined rather than queried as a model
+func Live(db *gorm.DB, table string) (string, []interface{}) {
+	at, ok := Time(db)
+	if !ok {
+		return fmt.Sprintf("%s.deleted_at IS NULL", table), nil
+	}
+	return fmt.Sprintf("%[1]s.created_at <= ? AND (%[1]s.deleted_at IS NULL OR %[1]s.deleted_at > ?)", table),
+		[]interface{}{at, at}
+}
+
+// scope is used to restrict a query of a model to the rows which
+// existed at the time its connection is evaluated as of
+func scope(s *gorm.Scope) {
+	value, ok := s.Get(setting)
+	if !ok {
+		return
+	}
+	at := value.(time.Time)
+	if at.IsZero() {
+		return
+	}
+	table := s.QuotedTableName()
+	if field, ok := s.FieldByName("CreatedAt"); ok {
+		s.Search.Where(fmt.Sprintf("%s.%s <= ?", table, s.Quote(field.DBName)), at)
+	}
+	if field, ok := s.FieldByName("DeletedAt"); ok {
+		column := fmt.Sprintf("%s.%s", table, s.Quote(field.DBName))
+		s.Search.Unscoped = true
+		s.Search.Where(fmt.Sprintf("(%[1]s IS NULL OR %[1]s > ?)", column), at)
+	}
+}
@@ -0,0 +1,88 @@
+package asof
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/config/v2"
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/jinzhu/gorm"
+)
+
+func TestApply(t *testing.T) {
+	cfg, err := config.LoadConfig("../testenv/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := fmt.Sprintf("asof-test-%v", time.Now().UnixNano())
+	beforeCreated := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	zone := &models.Zone{UserName: "testuser", Name: name}
+	if err := db.Create(zone).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(zone)
+	time.Sleep(10 * time.Millisecond)
+	beforeDeleted := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if err := db.Delete(zone).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	countZones := func(db *gorm.DB) int {
+		var count int
+		if err := db.Model(&models.Zone{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+	if n := countZones(db); n != 0 {
+		t.Fatalf("expected deleted zone to be excluded now, counted %v", n)
+	}
+	if n := countZones(Apply(db, beforeCreated)); n != 0 {
+		t.Fatalf("expected zone to be excluded before it was created, counted %v", n)
+	}
+	if n := countZones(Apply(db, beforeDeleted)); n != 1 {
+		t.Fatalf("expected zone to be included before it was deleted, counted %v", n)
+	}
+	if n := countZones(Remove(Apply(db, beforeDeleted))); n != 0 {
+		t.Fatalf("expected removing as of to evaluate now, counted %v", n)
+	}
+	var zones []models.Zone
+	if err := Apply(db, beforeDeleted).Where("name = ?", name).Find(&zones).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(zones) != 1 {
+		t.Fatalf("expected to find the zone before it was deleted, found %v", len(zones))
+	}
+	if !Now(Apply(db, beforeDeleted)).Equal(beforeDeleted) {
+		t.Fatal("expected now to be the as of time")
+	}
+	if condition, args := Live(db, "zones"); condition != "zones.deleted_at IS NULL" || len(args) != 0 {
+		t.Fatalf("unexpected condition %s", condition)
+	}
+	condition, args := Live(Apply(db, beforeDeleted), "zones")
+	if len(args) != 2 {
+		t.Fatalf("expected 2 arguments got %v", len(args))
+	}
+	var count int
+	if err := db.Unscoped().Model(&models.Zone{}).Where("name = ? AND "+condition, append([]interface{}{name}, args...)...).
+		Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("expected joined zone to be live before it was deleted, counted %v", count)
+	}
+}
+
+func openDatabaseConnection(cfg *config.TemporalConfig) (*gorm.DB, error) {
+	dbConnURL := fmt.Sprintf("host=127.0.0.1 port=%s user=postgres dbname=temporal password=%s sslmode=disable",
+		cfg.Database.Port, cfg.Database.Password)
+
+	return gorm.Open("postgres", dbConnURL)
+}
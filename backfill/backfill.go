@@ -0,0 +1,156 @@
+// Package backfill reconstructs the daily history of metrics from
+// the created_at and deleted_at timestamps of temporal's records,
+// so that the metrics store holds history from before tfarmer ran
+package backfill
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/tfarmer/store"
+	"github.com/jinzhu/gorm"
+)
+
+// RunID is the run id of backfilled points. as points recorded with the
+// same name, labels, time and run id replace each other, running a
+// backfill again replaces the points of previous backfills.
+const RunID = "backfill"
+
+// dayFormat is used to key values by day
+const dayFormat = "2006-01-02"
+
+// Metrics are the groups of metrics which can be backfilled
+var Metrics = []string{"signups", "uploads", "payments", "ipns", "zones"}
+
+// Farmer is the backfill farmer for Temporal
+type Farmer struct {
+	DB *gorm.DB
+}
+
+// NewFarmer instantiates our backfill farmer class
+func NewFarmer(db *gorm.DB) *Farmer {
+	return &Farmer{DB: db}
+}
+
+// Backfill is used to compute a point per day for each metric of the
+// given group, for every day from since until the day before until
+func (f *Farmer) Backfill(group string, since, until time.Time) ([]store.Point, error) {
+	since, until = day(since), day(until)
+	switch group {
+	case "signups":
+		return f.lifecycle(&models.User{}, "users.signups", "users.registered", since, until)
+	case "uploads":
+		return f.lifecycle(&models.Upload{}, "uploads.created", "uploads.total", since, until)
+	case "ipns":
+		return f.lifecycle(&models.IPNS{}, "ipns.records_created", "ipns.total", since, until)
+	case "zones":
+		points, err := f.lifecycle(&models.Zone{}, "tns.zones_created", "tns.zones", since, until)
+		if err != nil {
+			return nil, err
+		}
+		// zones created are recorded per period by the tns farmer
+		for i := range points {
+			if points[i].Name == "tns.zones_created" {
+				points[i].Labels = map[string]string{"period": "day"}
+			}
+		}
+		return points, nil
+	case "payments":
+		return f.payments(since, until)
+	default:
+		return nil, fmt.Errorf("unsupported metric group '%s'", group)
+	}
+}
+
+// lifecycle is used to compute the number of rows of the model created on
+// each day, and the number of rows which existed at the end of each day
+func (f *Farmer) lifecycle(model interface{}, created, total string, since, until time.Time) ([]store.Point, error) {
+	var base int
+	if err := f.DB.Unscoped().Model(model).
+		Where("created_at < ? AND (deleted_at IS NULL OR deleted_at >= ?)", since, since).
+		Count(&base).Error; err != nil {
+		return nil, err
+	}
+	creations, err := f.perDay(f.DB.Unscoped().Model(model).Select("date_trunc('day', created_at AT TIME ZONE 'UTC') AS day, count(*)").
+		Where("created_at >= ? AND created_at < ?", since, until))
+	if err != nil {
+		return nil, err
+	}
+	deletions, err := f.perDay(f.DB.Unscoped().Model(model).Select("date_trunc('day', deleted_at AT TIME ZONE 'UTC') AS day, count(*)").
+		Where("deleted_at >= ? AND deleted_at < ?", since, until))
+	if err != nil {
+		return nil, err
+	}
+	return daily(created, total, float64(base), creations, deletions, since, until), nil
+}
+
+// payments is used to compute the number of payments created on each
+// day, and the confirmed revenue of each day as recorded by the payment farmer
+func (f *Farmer) payments(since, until time.Time) ([]store.Point, error) {
+	created, err := f.perDay(f.DB.Model(&models.Payments{}).Select("date_trunc('day', created_at AT TIME ZONE 'UTC') AS day, count(*)").
+		Where("created_at >= ? AND created_at < ?", since, until))
+	if err != nil {
+		return nil, err
+	}
+	revenue, err := f.perDay(f.DB.Model(&models.Payments{}).Select("date_trunc('day', created_at AT TIME ZONE 'UTC') AS day, sum(usd_value)").
+		Where("confirmed = ? AND created_at >= ? AND created_at < ?", true, since, until))
+	if err != nil {
+		return nil, err
+	}
+	var points []store.Point
+	for d := since; d.Before(until); d = d.AddDate(0, 0, 1) {
+		key := d.Format(dayFormat)
+		points = append(points,
+			store.Point{Time: d, Name: "payments.created", Value: created[key], RunID: RunID},
+			store.Point{Time: d, Name: "payments.revenue", Value: revenue[key], RunID: RunID,
+				Labels: map[string]string{"period": "day"}},
+		)
+	}
+	return points, nil
+}
+
+// perDay is used to run a query selecting a day and a value,
+// grouped by day, returning the values keyed by day
+func (f *Farmer) perDay(db *gorm.DB) (map[string]float64, error) {
+	rows, err := db.Group("day").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := make(map[string]float64)
+	for rows.Next() {
+		var (
+			d     time.Time
+			value float64
+		)
+		if err := rows.Scan(&d, &value); err != nil {
+			return nil, err
+		}
+		values[d.UTC().Format(dayFormat)] += value
+	}
+	return values, rows.Err()
+}
+
+// daily is used to build the points of a metric counting the rows created each
+// day, and of a metric counting the rows which existed at the end of each day,
+// starting from the base number of rows which existed at since. points are
+// timestamped with the start of their day.
+func daily(created, total string, base float64, creations, deletions map[string]float64, since, until time.Time) []store.Point {
+	var points []store.Point
+	for d := since; d.Before(until); d = d.AddDate(0, 0, 1) {
+		key := d.Format(dayFormat)
+		base += creations[key] - deletions[key]
+		points = append(points,
+			store.Point{Time: d, Name: created, Value: creations[key], RunID: RunID},
+			store.Point{Time: d, Name: total, Value: base, RunID: RunID},
+		)
+	}
+	return points
+}
+
+// day returns the start of the day of the given time, in utc
+func day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
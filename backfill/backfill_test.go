@@ -0,0 +1,75 @@
+package backfill
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/config/v2"
+	"github.com/jinzhu/gorm"
+)
+
+func TestDaily(t *testing.T) {
+	since := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := daily("users.signups", "users.registered", 10,
+		map[string]float64{"2019-01-01": 2, "2019-01-03": 5},
+		map[string]float64{"2019-01-02": 1, "2019-01-03": 1},
+		since, since.AddDate(0, 0, 3),
+	)
+	if len(points) != 6 {
+		t.Fatalf("expected 6 points got %v", len(points))
+	}
+	var (
+		signups    = []float64{2, 0, 5}
+		registered = []float64{12, 11, 15}
+	)
+	for i := 0; i < 3; i++ {
+		created, total := points[2*i], points[2*i+1]
+		if !created.Time.Equal(since.AddDate(0, 0, i)) || !total.Time.Equal(created.Time) {
+			t.Fatalf("unexpected time for day %v: %s", i, created.Time)
+		}
+		if created.Name != "users.signups" || created.Value != signups[i] {
+			t.Fatalf("unexpected signups for day %v: %+v", i, created)
+		}
+		if total.Name != "users.registered" || total.Value != registered[i] {
+			t.Fatalf("unexpected registered users for day %v: %+v", i, total)
+		}
+		if created.RunID != RunID || total.RunID != RunID {
+			t.Fatal("expected backfill run id")
+		}
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	cfg, err := config.LoadConfig("../testenv/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf := NewFarmer(db)
+	until := time.Now()
+	since := until.AddDate(0, 0, -7)
+	for _, group := range Metrics {
+		points, err := bf.Backfill(group, since, until)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// two metrics for each of the last 7 complete days
+		if len(points) != 14 {
+			t.Fatalf("expected 14 %s points got %v", group, len(points))
+		}
+	}
+	if _, err := bf.Backfill("keys", since, until); err == nil {
+		t.Fatal("expected error for unsupported metric group")
+	}
+}
+
+func openDatabaseConnection(cfg *config.TemporalConfig) (*gorm.DB, error) {
+	dbConnURL := fmt.Sprintf("host=127.0.0.1 port=%s user=postgres dbname=temporal password=%s sslmode=disable",
+		cfg.Database.Port, cfg.Database.Password)
+
+	return gorm.Open("postgres", dbConnURL)
+}
@@ -0,0 +1,400 @@
+// Package chart renders metric history as line, bar and stacked area
+// charts, in svg or png, without depending on a graphics library
+package chart
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/RTradeLtd/tfarmer/store"
+)
+
+// Kind is the kind of chart to render
+type Kind string
+
+const (
+	// Line charts draw each series as a line
+	Line Kind = "line"
+	// Bar charts draw the values of each day as a group of bars
+	Bar Kind = "bar"
+	// StackedArea charts draw each series as an area stacked on the previous series
+	StackedArea Kind = "stacked"
+)
+
+const (
+	// DefaultWidth is the width of charts which don't set one
+	DefaultWidth = 640
+	// DefaultHeight is the height of charts which don't set one
+	DefaultHeight = 320
+)
+
+// Point is a single value of a series
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is a named sequence of values, ordered by time
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+// Chart is a chart of one or more series
+type Chart struct {
+	Title  string
+	Kind   Kind
+	Series []Series
+	Width  int
+	Height int
+}
+
+// palette are the colors given to each series, in order
+var palette = []color.RGBA{
+	{R: 0x1f, G: 0x77, B: 0xb4, A: 0xff},
+	{R: 0xff, G: 0x7f, B: 0x0e, A: 0xff},
+	{R: 0x2c, G: 0xa0, B: 0x2c, A: 0xff},
+	{R: 0xd6, G: 0x27, B: 0x28, A: 0xff},
+	{R: 0x94, G: 0x67, B: 0xbd, A: 0xff},
+	{R: 0x8c, G: 0x56, B: 0x4b, A: 0xff},
+}
+
+var (
+	white = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	black = color.RGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff}
+	grey  = color.RGBA{R: 0xdd, G: 0xdd, B: 0xdd, A: 0xff}
+)
+
+// margins around the plot area
+const (
+	marginLeft   = 60
+	marginRight  = 30
+	marginTop    = 50
+	marginBottom = 30
+)
+
+// anchor is the horizontal alignment of text
+type anchor int
+
+const (
+	anchorStart anchor = iota
+	anchorMiddle
+	anchorEnd
+)
+
+// xy is a point on the canvas
+type xy struct{ x, y float64 }
+
+// canvas is what charts are drawn on. coordinates are in pixels from the
+// top left, and text is vertically centered on y. size 1 is regular text,
+// and size 2 is used for titles.
+type canvas interface {
+	rect(x, y, w, h float64, c color.RGBA)
+	polygon(points []xy, c color.RGBA)
+	polyline(points []xy, width float64, c color.RGBA)
+	text(x, y float64, s string, size int, a anchor, c color.RGBA)
+}
+
+// SVG is used to render the chart as an svg image
+func (c *Chart) SVG(w io.Writer) error {
+	width, height := c.size()
+	v := newVector(width, height)
+	c.draw(v, width, height)
+	return v.write(w)
+}
+
+// PNG is used to render the chart as a png image
+func (c *Chart) PNG(w io.Writer) error {
+	width, height := c.size()
+	r := newRaster(width, height)
+	c.draw(r, width, height)
+	return r.write(w)
+}
+
+func (c *Chart) size() (int, int) {
+	width, height := c.Width, c.Height
+	if width <= 0 {
+		width = DefaultWidth
+	}
+	if height <= 0 {
+		height = DefaultHeight
+	}
+	return width, height
+}
+
+// draw is used to draw the chart on the given canvas
+func (c *Chart) draw(cv canvas, width, height int) {
+	var (
+		w      = float64(width)
+		h      = float64(height)
+		left   = float64(marginLeft)
+		right  = w - marginRight
+		top    = float64(marginTop)
+		bottom = h - marginBottom
+	)
+	cv.rect(0, 0, w, h, white)
+	cv.text(left, 16, c.Title, 2, anchorStart, black)
+	if len(c.Series) > 1 {
+		x := left
+		for i, s := range c.Series {
+			cv.rect(x, 31, 8, 8, palette[i%len(palette)])
+			cv.text(x+12, 35, s.Name, 1, anchorStart, black)
+			x += 24 + textWidth(s.Name, 1)
+		}
+	}
+
+	times := c.times()
+	if len(times) == 0 {
+		cv.text((left+right)/2, (top+bottom)/2, "no data", 1, anchorMiddle, black)
+		return
+	}
+
+	// y axis
+	min, max := c.valueRange(times)
+	ticks := niceTicks(min, max, 5)
+	min, max = ticks[0], ticks[len(ticks)-1]
+	yOf := func(v float64) float64 { return bottom - (v-min)/(max-min)*(bottom-top) }
+	for _, tick := range ticks {
+		y := yOf(tick)
+		cv.rect(left, y, right-left, 1, grey)
+		cv.text(left-6, y, formatValue(tick), 1, anchorEnd, black)
+	}
+
+	// x axis and series
+	switch c.Kind {
+	case Bar:
+		c.drawBars(cv, times, left, right, bottom, yOf)
+	case StackedArea:
+		xOf := timeScale(times, left, right)
+		c.drawTimeTicks(cv, times, bottom, xOf)
+		c.drawStacked(cv, times, xOf, yOf)
+	default:
+		xOf := timeScale(times, left, right)
+		c.drawTimeTicks(cv, times, bottom, xOf)
+		for i, s := range c.Series {
+			points := make([]xy, 0, len(s.Points))
+			for _, p := range s.Points {
+				points = append(points, xy{xOf(p.Time), yOf(p.Value)})
+			}
+			if len(points) == 1 {
+				cv.rect(points[0].x-2, points[0].y-2, 4, 4, palette[i%len(palette)])
+				continue
+			}
+			cv.polyline(points, 2, palette[i%len(palette)])
+		}
+	}
+	cv.rect(left, bottom, right-left, 1, black)
+}
+
+// drawBars is used to draw a group of bars for each time, one per series
+func (c *Chart) drawBars(cv canvas, times []time.Time, left, right, bottom float64, yOf func(float64) float64) {
+	var (
+		slot  = (right - left) / float64(len(times))
+		barW  = slot * 0.8 / float64(len(c.Series))
+		every = int(math.Ceil(float64(len(times)) / 8))
+		span  = times[len(times)-1].Sub(times[0])
+	)
+	for i, t := range times {
+		x := left + float64(i)*slot + slot*0.1
+		for j, s := range c.Series {
+			v, ok := valueAt(s, t)
+			if !ok {
+				continue
+			}
+			y0, y1 := yOf(0), yOf(v)
+			if y1 > y0 {
+				y0, y1 = y1, y0
+			}
+			cv.rect(x+float64(j)*barW, y1, barW, y0-y1, palette[j%len(palette)])
+		}
+		if i%every == 0 {
+			cv.text(left+(float64(i)+0.5)*slot, bottom+14, formatTime(t, span), 1, anchorMiddle, black)
+		}
+	}
+}
+
+// drawStacked is used to draw each series as an area on top of the previous series
+func (c *Chart) drawStacked(cv canvas, times []time.Time, xOf func(time.Time) float64, yOf func(float64) float64) {
+	base := make([]float64, len(times))
+	for i, s := range c.Series {
+		var upper, lower []xy
+		for k, t := range times {
+			v, _ := valueAt(s, t)
+			lower = append(lower, xy{xOf(t), yOf(base[k])})
+			base[k] += v
+			upper = append(upper, xy{xOf(t), yOf(base[k])})
+		}
+		area := upper
+		for k := len(lower) - 1; k >= 0; k-- {
+			area = append(area, lower[k])
+		}
+		cv.polygon(area, palette[i%len(palette)])
+	}
+}
+
+// drawTimeTicks is used to label the x axis of a chart scaled by time
+func (c *Chart) drawTimeTicks(cv canvas, times []time.Time, bottom float64, xOf func(time.Time) float64) {
+	first, last := times[0], times[len(times)-1]
+	span := last.Sub(first)
+	if span == 0 {
+		cv.text(xOf(first), bottom+14, formatTime(first, span), 1, anchorMiddle, black)
+		return
+	}
+	for i := 0; i <= 4; i++ {
+		t := first.Add(span * time.Duration(i) / 4)
+		cv.text(xOf(t), bottom+14, formatTime(t, span), 1, anchorMiddle, black)
+	}
+}
+
+// times returns every time with a value in any series, in order
+func (c *Chart) times() []time.Time {
+	seen := make(map[int64]bool)
+	var times []time.Time
+	for _, s := range c.Series {
+		for _, p := range s.Points {
+			if !seen[p.Time.UnixNano()] {
+				seen[p.Time.UnixNano()] = true
+				times = append(times, p.Time)
+			}
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times
+}
+
+// valueRange returns the range of values the y axis must cover.
+// bar and stacked area charts always include 0.
+func (c *Chart) valueRange(times []time.Time) (float64, float64) {
+	min, max := math.Inf(1), math.Inf(-1)
+	if c.Kind == StackedArea {
+		min = 0
+		for _, t := range times {
+			var sum float64
+			for _, s := range c.Series {
+				v, _ := valueAt(s, t)
+				sum += v
+			}
+			max = math.Max(max, sum)
+		}
+		return min, max
+	}
+	for _, s := range c.Series {
+		for _, p := range s.Points {
+			min = math.Min(min, p.Value)
+			max = math.Max(max, p.Value)
+		}
+	}
+	if c.Kind == Bar {
+		min, max = math.Min(min, 0), math.Max(max, 0)
+	}
+	return min, max
+}
+
+// valueAt returns the value of the series at the given time
+func valueAt(s Series, t time.Time) (float64, bool) {
+	i := sort.Search(len(s.Points), func(i int) bool { return !s.Points[i].Time.Before(t) })
+	if i < len(s.Points) && s.Points[i].Time.Equal(t) {
+		return s.Points[i].Value, true
+	}
+	return 0, false
+}
+
+// timeScale returns a function mapping times onto [left, right]
+func timeScale(times []time.Time, left, right float64) func(time.Time) float64 {
+	first, last := times[0], times[len(times)-1]
+	span := float64(last.Sub(first))
+	if span == 0 {
+		return func(time.Time) float64 { return (left + right) / 2 }
+	}
+	return func(t time.Time) float64 {
+		return left + float64(t.Sub(first))/span*(right-left)
+	}
+}
+
+// niceTicks returns roughly n evenly spaced round values covering [min, max]
+func niceTicks(min, max float64, n int) []float64 {
+	if max <= min {
+		max = min + 1
+	}
+	raw := (max - min) / float64(n)
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := magnitude * 10
+	for _, m := range []float64{1, 2, 5, 10} {
+		if raw <= m*magnitude {
+			step = m * magnitude
+			break
+		}
+	}
+	lo, hi := math.Floor(min/step)*step, math.Ceil(max/step)*step
+	var ticks []float64
+	for v := lo; v <= hi+step/2; v += step {
+		ticks = append(ticks, v)
+	}
+	return ticks
+}
+
+// formatValue formats an axis value compactly, such as 1.5k or 20M
+func formatValue(v float64) string {
+	for _, unit := range []struct {
+		size   float64
+		suffix string
+	}{{1e12, "T"}, {1e9, "G"}, {1e6, "M"}, {1e3, "k"}} {
+		if math.Abs(v) >= unit.size {
+			return trimFloat(v/unit.size) + unit.suffix
+		}
+	}
+	return trimFloat(v)
+}
+
+func trimFloat(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.3g", v)
+}
+
+// formatTime formats an axis time, with a precision suited to the span of the axis
+func formatTime(t time.Time, span time.Duration) string {
+	switch {
+	case span >= 2*365*24*time.Hour:
+		return t.Format("2006-01")
+	case span < 48*time.Hour && span > 0:
+		return t.Format("15:04")
+	default:
+		return t.Format("01-02")
+	}
+}
+
+// FromPoints is used to build series from metric history, with one series
+// for each value of the given label, or a single series if label is empty.
+// series hold the last value recorded on each day, at the start of the day.
+func FromPoints(points []store.Point, label string) []Series {
+	var (
+		names  []string
+		groups = make(map[string][]store.Point)
+	)
+	for _, p := range points {
+		name := p.Name
+		if label != "" {
+			name = p.Labels[label]
+		}
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], p)
+	}
+	sort.Strings(names)
+	series := make([]Series, 0, len(names))
+	for _, name := range names {
+		s := Series{Name: name}
+		for _, p := range store.Daily(groups[name]) {
+			s.Points = append(s.Points, Point{Time: p.Time, Value: p.Value})
+		}
+		series = append(series, s)
+	}
+	return series
+}
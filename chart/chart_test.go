@@ -0,0 +1,133 @@
+package chart
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/tfarmer/store"
+)
+
+var testStart = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func testSeries() []Series {
+	free, paid := Series{Name: "free"}, Series{Name: "paid"}
+	for i := 0; i < 10; i++ {
+		day := testStart.AddDate(0, 0, i)
+		free.Points = append(free.Points, Point{Time: day, Value: float64(100 + i*10)})
+		paid.Points = append(paid.Points, Point{Time: day, Value: float64(10 + i)})
+	}
+	return []Series{free, paid}
+}
+
+func TestSVG(t *testing.T) {
+	for kind, element := range map[Kind]string{Line: "<polyline", Bar: "<rect", StackedArea: "<polygon"} {
+		var buf bytes.Buffer
+		c := &Chart{Title: "users & uploads", Kind: kind, Series: testSeries()}
+		if err := c.SVG(&buf); err != nil {
+			t.Fatal(err)
+		}
+		svg := buf.String()
+		if !strings.HasPrefix(svg, "<svg") || !strings.HasSuffix(svg, "</svg>\n") {
+			t.Fatalf("%s chart isn't an svg document", kind)
+		}
+		if !strings.Contains(svg, "users &amp; uploads") {
+			t.Fatalf("%s chart title wasn't escaped", kind)
+		}
+		if !strings.Contains(svg, element) || !strings.Contains(svg, hex(palette[1])) {
+			t.Fatalf("%s chart is missing its series", kind)
+		}
+	}
+	var buf bytes.Buffer
+	if err := (&Chart{Title: "empty"}).SVG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "no data") {
+		t.Fatal("expected empty chart to say there's no data")
+	}
+}
+
+func TestPNG(t *testing.T) {
+	for _, kind := range []Kind{Line, Bar, StackedArea} {
+		var buf bytes.Buffer
+		c := &Chart{Title: "users", Kind: kind, Series: testSeries(), Width: 300, Height: 200}
+		if err := c.PNG(&buf); err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != 300 || b.Dy() != 200 {
+			t.Fatalf("unexpected %s chart size %v", kind, b)
+		}
+		// both series must have been drawn
+		seen := make(map[int]bool)
+		for y := 0; y < 200; y++ {
+			for x := 0; x < 300; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+				for i, c := range palette[:2] {
+					if uint8(r>>8) == c.R && uint8(g>>8) == c.G && uint8(b>>8) == c.B {
+						seen[i] = true
+					}
+				}
+			}
+		}
+		if !seen[0] || !seen[1] {
+			t.Fatalf("%s chart is missing a series", kind)
+		}
+	}
+}
+
+func TestNiceTicks(t *testing.T) {
+	ticks := niceTicks(3, 97, 5)
+	expected := []float64{0, 20, 40, 60, 80, 100}
+	if len(ticks) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, ticks)
+	}
+	for i := range ticks {
+		if ticks[i] != expected[i] {
+			t.Fatalf("expected %v got %v", expected, ticks)
+		}
+	}
+	// a flat series must still get a range
+	if ticks := niceTicks(5, 5, 5); len(ticks) < 2 {
+		t.Fatalf("expected a range for a flat series got %v", ticks)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	for v, expected := range map[float64]string{
+		0: "0", 12: "12", 0.25: "0.25", 1500: "1.5k", 20e6: "20M", 3e9: "3G",
+	} {
+		if got := formatValue(v); got != expected {
+			t.Fatalf("expected %s for %v got %s", expected, v, got)
+		}
+	}
+}
+
+func TestFromPoints(t *testing.T) {
+	series := FromPoints([]store.Point{
+		{Time: testStart.Add(2 * time.Hour), Name: "keys.per_tier", Value: 1, Labels: map[string]string{"tier": "paid"}},
+		{Time: testStart.Add(time.Hour), Name: "keys.per_tier", Value: 5, Labels: map[string]string{"tier": "free"}},
+		// the last value of a day wins, whatever order points are given in
+		{Time: testStart.Add(3 * time.Hour), Name: "keys.per_tier", Value: 6, Labels: map[string]string{"tier": "free"}},
+		{Time: testStart.Add(2 * time.Hour), Name: "keys.per_tier", Value: 4, Labels: map[string]string{"tier": "free"}},
+		{Time: testStart.AddDate(0, 0, 1), Name: "keys.per_tier", Value: 7, Labels: map[string]string{"tier": "free"}},
+	}, "tier")
+	if len(series) != 2 || series[0].Name != "free" || series[1].Name != "paid" {
+		t.Fatalf("unexpected series %+v", series)
+	}
+	free := series[0].Points
+	if len(free) != 2 || free[0].Value != 6 || free[1].Value != 7 {
+		t.Fatalf("unexpected free series %+v", free)
+	}
+	if !free[0].Time.Equal(testStart) {
+		t.Fatalf("expected points at the start of the day, got %s", free[0].Time)
+	}
+	if series := FromPoints([]store.Point{{Time: testStart, Name: "users.registered", Value: 1}}, ""); series[0].Name != "users.registered" {
+		t.Fatalf("expected series named after the metric, got %s", series[0].Name)
+	}
+}
@@ -0,0 +1,125 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+)
+
+// raster is a canvas which draws pixels onto an image
+type raster struct {
+	img *image.RGBA
+}
+
+func newRaster(width, height int) *raster {
+	return &raster{img: image.NewRGBA(image.Rect(0, 0, width, height))}
+}
+
+// rect fills every pixel whose center lies within the rectangle. rectangles
+// thinner than a pixel are widened to one pixel, so grid lines are visible.
+func (r *raster) rect(x, y, w, h float64, c color.RGBA) {
+	if w < 1 {
+		x, w = x+w/2-0.5, 1
+	}
+	if h < 1 {
+		y, h = y+h/2-0.5, 1
+	}
+	for py := int(math.Ceil(y - 0.5)); float64(py)+0.5 < y+h; py++ {
+		for px := int(math.Ceil(x - 0.5)); float64(px)+0.5 < x+w; px++ {
+			r.set(px, py, c)
+		}
+	}
+}
+
+// polygon fills the polygon using the even-odd rule, sampling pixel centers
+func (r *raster) polygon(points []xy, c color.RGBA) {
+	if len(points) < 3 {
+		return
+	}
+	minY, maxY := points[0].y, points[0].y
+	for _, p := range points {
+		minY, maxY = math.Min(minY, p.y), math.Max(maxY, p.y)
+	}
+	var crossings []float64
+	for py := int(math.Floor(minY)); py <= int(math.Ceil(maxY)); py++ {
+		cy := float64(py) + 0.5
+		crossings = crossings[:0]
+		for i := range points {
+			a, b := points[i], points[(i+1)%len(points)]
+			if (a.y <= cy && b.y > cy) || (b.y <= cy && a.y > cy) {
+				crossings = append(crossings, a.x+(cy-a.y)/(b.y-a.y)*(b.x-a.x))
+			}
+		}
+		sort.Float64s(crossings)
+		for i := 0; i+1 < len(crossings); i += 2 {
+			for px := int(math.Ceil(crossings[i] - 0.5)); float64(px)+0.5 < crossings[i+1]; px++ {
+				r.set(px, py, c)
+			}
+		}
+	}
+}
+
+// polyline draws each segment as a filled quad of the given width,
+// with a square at each joint so that corners aren't left open
+func (r *raster) polyline(points []xy, width float64, c color.RGBA) {
+	half := math.Max(width, 1) / 2
+	for i := 0; i+1 < len(points); i++ {
+		a, b := points[i], points[i+1]
+		length := math.Hypot(b.x-a.x, b.y-a.y)
+		if length == 0 {
+			continue
+		}
+		// offset perpendicular to the segment
+		nx, ny := -(b.y-a.y)/length*half, (b.x-a.x)/length*half
+		r.polygon([]xy{
+			{a.x + nx, a.y + ny}, {b.x + nx, b.y + ny},
+			{b.x - nx, b.y - ny}, {a.x - nx, a.y - ny},
+		}, c)
+	}
+	for _, p := range points {
+		r.rect(p.x-half, p.y-half, 2*half, 2*half, c)
+	}
+}
+
+// text draws the string with the bitmap font, scaled by size
+func (r *raster) text(x, y float64, s string, size int, a anchor, c color.RGBA) {
+	width := textWidth(s, size)
+	switch a {
+	case anchorMiddle:
+		x -= width / 2
+	case anchorEnd:
+		x -= width
+	}
+	left := int(math.Round(x))
+	top := int(math.Round(y - glyphHeight*float64(size)/2))
+	for _, ch := range s {
+		g := glyph(ch)
+		for row := 0; row < glyphHeight; row++ {
+			for col := 0; col < glyphWidth; col++ {
+				if g[row]&(1<<uint(glyphWidth-1-col)) == 0 {
+					continue
+				}
+				for dy := 0; dy < size; dy++ {
+					for dx := 0; dx < size; dx++ {
+						r.set(left+col*size+dx, top+row*size+dy, c)
+					}
+				}
+			}
+		}
+		left += glyphAdvance * size
+	}
+}
+
+func (r *raster) set(x, y int, c color.RGBA) {
+	if image.Pt(x, y).In(r.img.Rect) {
+		r.img.SetRGBA(x, y, c)
+	}
+}
+
+// write is used to encode the image as a png
+func (r *raster) write(w io.Writer) error {
+	return png.Encode(w, r.img)
+}
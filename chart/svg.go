@@ -0,0 +1,64 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// vector is a canvas which draws svg elements
+type vector struct {
+	buf bytes.Buffer
+}
+
+func newVector(width, height int) *vector {
+	v := &vector{}
+	fmt.Fprintf(&v.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height)
+	return v
+}
+
+func (v *vector) rect(x, y, w, h float64, c color.RGBA) {
+	fmt.Fprintf(&v.buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n",
+		x, y, w, h, hex(c))
+}
+
+func (v *vector) polygon(points []xy, c color.RGBA) {
+	fmt.Fprintf(&v.buf, `<polygon points="%s" fill="%s"/>`+"\n", pointList(points), hex(c))
+}
+
+func (v *vector) polyline(points []xy, width float64, c color.RGBA) {
+	fmt.Fprintf(&v.buf, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%.1f"/>`+"\n",
+		pointList(points), hex(c), width)
+}
+
+func (v *vector) text(x, y float64, s string, size int, a anchor, c color.RGBA) {
+	anchors := map[anchor]string{anchorStart: "start", anchorMiddle: "middle", anchorEnd: "end"}
+	fmt.Fprintf(&v.buf, `<text x="%.1f" y="%.1f" font-family="sans-serif" font-size="%d" `+
+		`text-anchor="%s" dominant-baseline="middle" fill="%s">`,
+		x, y, 4+7*size, anchors[a], hex(c))
+	xml.EscapeText(&v.buf, []byte(s))
+	v.buf.WriteString("</text>\n")
+}
+
+// write is used to write the finished svg document
+func (v *vector) write(w io.Writer) error {
+	v.buf.WriteString("</svg>\n")
+	_, err := v.buf.WriteTo(w)
+	return err
+}
+
+func pointList(points []xy) string {
+	parts := make([]string, 0, len(points))
+	for _, p := range points {
+		parts = append(parts, fmt.Sprintf("%.1f,%.1f", p.x, p.y))
+	}
+	return strings.Join(parts, " ")
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
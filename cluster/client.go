@@ -0,0 +1,112 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Pin statuses reported by the cluster api
+const (
+	StatusPinned   = "pinned"
+	StatusPinning  = "pinning"
+	StatusPinError = "pin_error"
+	StatusUnpinned = "unpinned"
+)
+
+// Client is a minimal client for the ipfs cluster rest api
+type Client struct {
+	url  string
+	http *http.Client
+}
+
+// NewClient is used to create a cluster api client for the given address
+func NewClient(address string, timeout time.Duration) *Client {
+	if !strings.HasPrefix(address, "http") {
+		address = "http://" + address
+	}
+	return &Client{
+		url:  strings.TrimSuffix(address, "/"),
+		http: &http.Client{Timeout: timeout},
+	}
+}
+
+// CID is a content hash as returned by the cluster api, which depending on
+// the cluster version is either a plain string or an object of the form {"/": hash}
+type CID string
+
+// UnmarshalJSON decodes either representation of a cid
+func (c *CID) UnmarshalJSON(data []byte) error {
+	var hash string
+	if err := json.Unmarshal(data, &hash); err == nil {
+		*c = CID(hash)
+		return nil
+	}
+	var link struct {
+		Hash string `json:"/"`
+	}
+	if err := json.Unmarshal(data, &link); err != nil {
+		return err
+	}
+	*c = CID(link.Hash)
+	return nil
+}
+
+// PinInfo is the status of a pin on a single cluster peer
+type PinInfo struct {
+	Peer   string `json:"peer"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+// GlobalPinInfo is the status of a pin across all cluster peers
+type GlobalPinInfo struct {
+	Cid     CID                `json:"cid"`
+	PeerMap map[string]PinInfo `json:"peer_map"`
+}
+
+// Allocation is a pin tracked by the cluster, along with its replication settings
+type Allocation struct {
+	Cid                  CID      `json:"cid"`
+	Allocations          []string `json:"allocations"`
+	ReplicationFactorMin int      `json:"replication_factor_min"`
+	ReplicationFactorMax int      `json:"replication_factor_max"`
+}
+
+// Pins is used to get the status of every pin across the cluster
+func (c *Client) Pins(ctx context.Context) ([]GlobalPinInfo, error) {
+	pins := []GlobalPinInfo{}
+	if err := c.get(ctx, "/pins", &pins); err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
+
+// Allocations is used to get every pin tracked by the cluster
+func (c *Client) Allocations(ctx context.Context) ([]Allocation, error) {
+	allocations := []Allocation{}
+	if err := c.get(ctx, "/allocations", &allocations); err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+// get is used to make a get request against the api, decoding the response into out
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, c.url+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cluster api returned status %v for %s", resp.StatusCode, path)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"context"
+
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/tfarmer/stats"
+	"github.com/jinzhu/gorm"
+)
+
+// used to scrape ipfs cluster related data
+
+// Farmer is the ipfs cluster farmer for Temporal
+type Farmer struct {
+	UM     *models.UploadManager
+	client *Client
+}
+
+// NewFarmer instantiates our ipfs cluster farmer class
+func NewFarmer(db *gorm.DB, client *Client) *Farmer {
+	return &Farmer{
+		UM:     models.NewUploadManager(db),
+		client: client,
+	}
+}
+
+// Summary describes the pins tracked by the cluster
+type Summary struct {
+	// Pins is the total number of cluster pins
+	Pins int
+	// ByStatus is the number of pins in each status. a pin's status is
+	// the least healthy status reported by the peers it is allocated to.
+	ByStatus map[string]int
+	// ReplicationFactors is a histogram of pin replication factors
+	ReplicationFactors *stats.Histogram
+	// MissingFromCluster is the number of public uploads which aren't pinned by the cluster
+	MissingFromCluster int
+	// MissingFromUploads is the number of cluster pins with no matching public upload
+	MissingFromUploads int
+}
+
+// statusPriority orders statuses from most to least healthy
+var statusPriority = map[string]int{
+	StatusUnpinned: 1,
+	StatusPinned:   2,
+	StatusPinning:  3,
+	StatusPinError: 4,
+}
+
+// PinSummary is used to summarize the pins tracked by the cluster,
+// cross-checking them against the uploads on the public network
+func (f *Farmer) PinSummary(ctx context.Context) (*Summary, error) {
+	pins, err := f.client.Pins(ctx)
+	if err != nil {
+		return nil, err
+	}
+	allocations, err := f.client.Allocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hashes := []string{}
+	if err := f.UM.DB.Model(&models.Upload{}).
+		Where("network_name = ?", "public").
+		Pluck("DISTINCT hash", &hashes).Error; err != nil {
+		return nil, err
+	}
+	uploads := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		uploads[hash] = true
+	}
+	return summarize(pins, allocations, uploads), nil
+}
+
+// summarize computes the pin summary from the cluster state and our uploads
+func summarize(pins []GlobalPinInfo, allocations []Allocation, uploads map[string]bool) *Summary {
+	s := &Summary{
+		Pins:     len(pins),
+		ByStatus: make(map[string]int),
+		ReplicationFactors: stats.NewHistogram(
+			[]float64{1, 2, 3, 4, 6},
+			[]string{"all peers", "1", "2", "3", "4-5", "6+"},
+		),
+	}
+	pinned := make(map[string]bool, len(pins))
+	for _, pin := range pins {
+		pinned[string(pin.Cid)] = true
+		var status string
+		for _, info := range pin.PeerMap {
+			// peers not tracking the pin report other statuses such as
+			// "remote", which say nothing about the health of the pin
+			if statusPriority[info.Status] > statusPriority[status] {
+				status = info.Status
+			}
+		}
+		if status == "" {
+			status = StatusUnpinned
+		}
+		s.ByStatus[status]++
+		if !uploads[string(pin.Cid)] {
+			s.MissingFromUploads++
+		}
+	}
+	for _, a := range allocations {
+		// a replication factor of -1 means the pin is replicated to every peer
+		s.ReplicationFactors.Add(float64(a.ReplicationFactorMin))
+	}
+	for hash := range uploads {
+		if !pinned[hash] {
+			s.MissingFromCluster++
+		}
+	}
+	return s
+}
@@ -0,0 +1,97 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	testCID1 = "QmS4ustL54uo8FzR9455qaxZwuMiUhyvMcX9Ba8nUH4uVv"
+	testCID2 = "QmYC4XWNHnh7gMkkXADZX9x9NLCX9f4MY6Wy9GmRpCBVH5"
+	testCID3 = "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"
+)
+
+func newFakeCluster() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pins", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[
+			{"cid": {"/": %q}, "peer_map": {
+				"peer1": {"peer": "peer1", "status": "pinned"},
+				"peer2": {"peer": "peer2", "status": "remote"}
+			}},
+			{"cid": %q, "peer_map": {
+				"peer1": {"peer": "peer1", "status": "pinned"},
+				"peer2": {"peer": "peer2", "status": "pin_error", "error": "timeout"}
+			}},
+			{"cid": {"/": %q}, "peer_map": {
+				"peer1": {"peer": "peer1", "status": "pinning"}
+			}}
+		]`, testCID1, testCID2, testCID3)
+	})
+	mux.HandleFunc("/allocations", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[
+			{"cid": {"/": %q}, "replication_factor_min": 1, "replication_factor_max": 2},
+			{"cid": %q, "replication_factor_min": 2, "replication_factor_max": 2},
+			{"cid": {"/": %q}, "replication_factor_min": -1, "replication_factor_max": -1}
+		]`, testCID1, testCID2, testCID3)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestClient(t *testing.T) {
+	server := newFakeCluster()
+	defer server.Close()
+	client := NewClient(server.URL, time.Minute)
+	pins, err := client.Pins(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 3 || pins[0].Cid != testCID1 || pins[1].Cid != testCID2 {
+		t.Fatalf("unexpected pins %+v", pins)
+	}
+	allocations, err := client.Allocations(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allocations) != 3 || allocations[2].ReplicationFactorMin != -1 {
+		t.Fatalf("unexpected allocations %+v", allocations)
+	}
+	if _, err := NewClient(server.URL+"/missing", time.Minute).Pins(context.Background()); err == nil {
+		t.Fatal("expected error for bad status")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	server := newFakeCluster()
+	defer server.Close()
+	client := NewClient(server.URL, time.Minute)
+	pins, err := client.Pins(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	allocations, err := client.Allocations(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := summarize(pins, allocations, map[string]bool{
+		testCID1: true,
+		testCID2: true,
+		"QmNotPinnedByTheClusterxxxxxxxxxxxxxxxxxxxxxxx": true,
+	})
+	if s.Pins != 3 {
+		t.Fatal("bad pin count")
+	}
+	if s.ByStatus[StatusPinned] != 1 || s.ByStatus[StatusPinError] != 1 || s.ByStatus[StatusPinning] != 1 {
+		t.Fatalf("unexpected statuses %+v", s.ByStatus)
+	}
+	if s.ReplicationFactors.String() != "all peers: 1, 1: 1, 2: 1, 3: 0, 4-5: 0, 6+: 0" {
+		t.Fatal("bad replication factors", s.ReplicationFactors)
+	}
+	if s.MissingFromCluster != 1 || s.MissingFromUploads != 1 {
+		t.Fatalf("bad cross-check %+v", s)
+	}
+}
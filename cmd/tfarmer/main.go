@@ -1,21 +1,44 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/RTradeLtd/rtfs/v2"
+	"github.com/RTradeLtd/tfarmer/asof"
+	"github.com/RTradeLtd/tfarmer/backfill"
+	"github.com/RTradeLtd/tfarmer/chart"
+	"github.com/RTradeLtd/tfarmer/cluster"
+	"github.com/RTradeLtd/tfarmer/forecast"
+	"github.com/RTradeLtd/tfarmer/ipns"
+	"github.com/RTradeLtd/tfarmer/keys"
 	"github.com/RTradeLtd/tfarmer/mail"
+	"github.com/RTradeLtd/tfarmer/network"
+	"github.com/RTradeLtd/tfarmer/node"
+	"github.com/RTradeLtd/tfarmer/payment"
+	"github.com/RTradeLtd/tfarmer/query"
+	"github.com/RTradeLtd/tfarmer/revenue"
+	"github.com/RTradeLtd/tfarmer/snapshot"
+	"github.com/RTradeLtd/tfarmer/stats"
+	"github.com/RTradeLtd/tfarmer/store"
+	"github.com/RTradeLtd/tfarmer/tns"
 	"github.com/RTradeLtd/tfarmer/upload"
 	"github.com/RTradeLtd/tfarmer/user"
+	"github.com/c2h5oh/datasize"
 	"github.com/jinzhu/gorm"
 
 	"github.com/RTradeLtd/cmd/v2"
 	"github.com/RTradeLtd/config/v2"
 	"github.com/RTradeLtd/database/v2"
+	"github.com/RTradeLtd/database/v2/models"
 )
 
 // Version denotes the tag of this build
@@ -25,6 +48,10 @@ var Version string
 var (
 	ctx    context.Context
 	cancel context.CancelFunc
+	// runID identifies the points recorded by this run
+	runID string
+	// runTime is when this run started, used as the time of recorded points
+	runTime time.Time
 )
 
 // command-line flags
@@ -39,6 +66,47 @@ var (
 	recipientName  *string
 	uploadType     *string
 	unique         *bool
+	days           *int
+	snapshotPath   *string
+	period         *string
+	recordMetrics  *bool
+	storeType      *string
+	storePath      *string
+	labels         *string
+	retention      *string
+	asOf           *string
+	// chart flags
+	chartOut  *string
+	chartKind *string
+	chartBy   *string
+	charts    *bool
+	chartDays *string
+	// forecast flags
+	forecastDays     *int
+	forecastHorizons *string
+	forecastHoldout  *int
+	// history flags
+	historyFrom   *string
+	historyTo     *string
+	historyStep   *string
+	historyFormat *string
+	historyFn     *string
+	// runway flags
+	runway        *bool
+	runwayModel   *string
+	runwayHorizon *int
+	runwayAlert   *int
+	capacityGB    *float64
+	// backfill flags
+	backfillSince   *string
+	backfillMetrics *string
+	// probe flags
+	probeSample      *int
+	probeConcurrency *int
+	probeTimeout     *time.Duration
+	// network flags
+	networkAPI     *string
+	networkTimeout *time.Duration
 	// bucket flags
 	bucketLocation *string
 )
@@ -55,6 +123,92 @@ func baseFlagSet() *flag.FlagSet {
 		"path to Temporal configuration")
 	unique = f.Bool("unique", false,
 		"toggle whether unique checks should be performed")
+	days = f.Int("days", 30,
+		"number of days to report on for daily metrics")
+	period = f.String("period", "day",
+		"period to group metrics by (day, week, month)")
+	snapshotPath = f.String("snapshot.path", "",
+		"path to the file used to store metric snapshots between runs")
+	recordMetrics = f.Bool("record", false,
+		"toggle whether metrics should be recorded in the metrics store")
+	storeType = f.String("store", "postgres",
+		"metrics store to use (postgres, file)")
+	storePath = f.String("store.path", "tfarmer-metrics",
+		"directory of the file metrics store")
+	labels = f.String("labels", "",
+		"comma separated key=value labels that metric history must have")
+	asOf = f.String("as-of", "",
+		"date or rfc3339 time to evaluate database metrics as of, excluding rows created after it "+
+			"and including rows deleted after it (defaults to now). "+asOfCaveat+". "+
+			"can't be used with record or snapshot.path")
+	retention = f.String("retention", "",
+		"comma separated retention of each resolution of metric history, such as raw=30d,hourly=90d,daily=forever "+
+			"(defaults to raw=30d,hourly=90d and keeping the rest forever)")
+
+	// probe configuration
+	probeSample = f.Int("probe.sample", 100,
+		"number of records to sample when probing")
+	probeConcurrency = f.Int("probe.concurrency", 10,
+		"number of records to probe at once")
+	probeTimeout = f.Duration("probe.timeout", 30*time.Second,
+		"how long to wait for a single probe")
+
+	// chart configuration
+	chartOut = f.String("out", "",
+		"file to write a chart to, as svg or png by extension (defaults to <metric>.svg)")
+	chartKind = f.String("chart.kind", "line",
+		"kind of chart to render (line, bar, stacked)")
+	chartBy = f.String("chart.by", "",
+		"label to split a chart into one series per value by")
+	charts = f.Bool("charts", false,
+		"toggle whether the daily email embeds charts from the metrics store")
+	chartDays = f.String("chart.days", "30,90",
+		"comma separated number of days covered by the charts of the daily email")
+
+	// forecast configuration
+	forecastDays = f.Int("forecast.days", 365,
+		"number of days of metric history to fit forecasts to")
+	forecastHorizons = f.String("forecast.horizons", "30,90,365",
+		"comma separated number of days ahead to forecast")
+	forecastHoldout = f.Int("forecast.holdout", 30,
+		"number of days of metric history to hold out when backtesting forecasts")
+
+	// history configuration
+	historyFrom = f.String("from", "",
+		"date or rfc3339 time to query metric history from (defaults to the days flag before to), "+
+			"or the date, time or run id to diff metrics from")
+	historyTo = f.String("to", "",
+		"date or rfc3339 time to query metric history until, or the date, time or run id to diff metrics to (defaults to now)")
+	historyStep = f.String("step", "1d",
+		"duration of each step of queried metric history, such as 1h, 1d or 1w")
+	historyFormat = f.String("format", "table",
+		"format to print queried metric history and diffs in (table, json, csv)")
+	historyFn = f.String("fn", "",
+		"comma separated functions to apply to queried metric history in order (rate, delta, avg:<steps>, sum, sum:<label>)")
+
+	// runway configuration
+	runway = f.Bool("runway", false,
+		"toggle whether the daily report includes storage runway from the metrics store")
+	runwayModel = f.String("runway.model", "linear",
+		"forecast model used to project storage runway (linear, exponential, holt-winters)")
+	runwayHorizon = f.Int("runway.horizon", 730,
+		"number of days ahead to project storage runway")
+	runwayAlert = f.Int("runway.alert", 60,
+		"number of days of storage runway below which the daily report raises an alert")
+	capacityGB = f.Float64("capacity.gb", 0,
+		"storage capacity of the ipfs node in GB (defaults to the node's storage max)")
+
+	// backfill configuration
+	backfillSince = f.String("since", "2018-01-01",
+		"date to backfill metrics from")
+	backfillMetrics = f.String("metrics", strings.Join(backfill.Metrics, ","),
+		"comma separated metric groups to backfill")
+
+	// network configuration
+	networkAPI = f.String("network.api", "",
+		"address of hosted network ipfs apis, with %s in place of the network name (defaults to nexus delegator)")
+	networkTimeout = f.Duration("network.timeout", 30*time.Second,
+		"how long to wait for a hosted network node to respond")
 
 	// db configuration
 	dbNoSSL = f.Bool("db.no_ssl", false,
@@ -73,14 +227,496 @@ func baseFlagSet() *flag.FlagSet {
 	return f
 }
 
-func newDB(cfg config.TemporalConfig, noSSL bool) (*gorm.DB, error) {
-	dbm, err := database.New(&cfg, database.Options{SSLModeDisable: noSSL})
+func newDB(cfg config.TemporalConfig, noSSL, migrate bool) (*gorm.DB, error) {
+	dbm, err := database.New(&cfg, database.Options{SSLModeDisable: noSSL, RunMigrations: migrate})
 	if err != nil {
 		return nil, err
 	}
 	return dbm.DB, nil
 }
 
+// openDatabase is used to open a connection to temporal's database with
+// newDB using the database flags, exiting if the connection can't be made
+func openDatabase(cfg config.TemporalConfig) *gorm.DB {
+	db, err := newDB(cfg, *dbNoSSL, *dbMigrate)
+	if err != nil {
+		fatal("failed to initialize database connection", err)
+	}
+	if *asOf != "" {
+		return asof.Apply(db, runTime)
+	}
+	return db
+}
+
+// openIPFS is used to open a connection to temporal's ipfs node,
+// exiting if the connection can't be made
+func openIPFS(cfg config.TemporalConfig) rtfs.Manager {
+	ipfs, err := rtfs.NewManager(
+		cfg.IPFS.APIConnection.Host+":"+cfg.IPFS.APIConnection.Port,
+		"", 1*time.Minute,
+	)
+	if err != nil {
+		fatal("failed to open ipfs api connection", err)
+	}
+	return ipfs
+}
+
+// report is used to print the lines of a report, and to
+// email them to the recipient if email is enabled
+func report(cfg config.TemporalConfig, db *gorm.DB, subject string, lines ...string) {
+	reportImages(cfg, db, subject, nil, lines...)
+}
+
+// reportImages is used to print the lines of a report, and to email
+// them with the given inline images to the recipient if email is enabled
+func reportImages(cfg config.TemporalConfig, db *gorm.DB, subject string, images []mail.InlineImage, lines ...string) {
+	if *asOf != "" {
+		at := runTime.Format(time.RFC3339)
+		subject += " as of " + at
+		lines = append([]string{"evaluated as of " + at + ", where " + asOfCaveat}, lines...)
+	}
+	fmt.Println(strings.Join(lines, "\n"))
+	if !*sendEmail {
+		return
+	}
+	mm, err := mail.NewManager(&cfg, db)
+	if err != nil {
+		fatal("failed to initialize mail manager", err)
+	}
+	content := strings.Join(lines, "<br>")
+	if len(images) == 0 {
+		_, err = mm.SendEmail(subject, content, "text/html", *recipientName, *emailRecipient)
+	} else {
+		for _, image := range images {
+			content += fmt.Sprintf(`<br><img src="cid:%s" alt="%s">`, image.ContentID, image.ContentID)
+		}
+		_, err = mm.SendEmailWithImages(subject, content, *recipientName, *emailRecipient, images...)
+	}
+	if err != nil {
+		fatal("failed to send email report", err)
+	}
+}
+
+// dailyCharts are the metrics charted in the daily email
+var dailyCharts = []struct {
+	metric string
+	title  string
+	kind   chart.Kind
+	labels map[string]string
+}{
+	{"users.registered", "registered users", chart.Line, nil},
+	{"uploads.total", "uploads", chart.Line, nil},
+	{node.RepoSizeMetric, "bytes stored", chart.Line, nil},
+	{"payments.revenue", "confirmed revenue (usd)", chart.Bar, map[string]string{"period": "day"}},
+}
+
+// renderDailyCharts is used to render the charts of the daily
+// email as png images, for each window set by the chart.days flag
+func renderDailyCharts(s store.Store) []mail.InlineImage {
+	var images []mail.InlineImage
+	for _, window := range strings.Split(*chartDays, ",") {
+		days, err := strconv.Atoi(strings.TrimSpace(window))
+		if err != nil {
+			fatal("failed to parse chart days", err)
+		}
+		for _, c := range dailyCharts {
+			points, err := store.History(s, c.metric, runTime.AddDate(0, 0, -days), runTime)
+			if err != nil {
+				fatal("failed to query "+c.metric, err)
+			}
+			var buf bytes.Buffer
+			if err := (&chart.Chart{
+				Title:  fmt.Sprintf("%s, last %v days", c.title, days),
+				Kind:   c.kind,
+				Series: chart.FromPoints(store.Filter(points, c.labels), ""),
+			}).PNG(&buf); err != nil {
+				fatal("failed to render chart", err)
+			}
+			images = append(images, mail.InlineImage{
+				ContentID:   fmt.Sprintf("%s-%vd.png", c.metric, days),
+				ContentType: "image/png",
+				Data:        buf.Bytes(),
+			})
+		}
+	}
+	return images
+}
+
+// takeSnapshot is used to record the given values in the
+// snapshot file, if one has been configured
+func takeSnapshot(values map[string]float64) {
+	if *snapshotPath == "" {
+		return
+	}
+	if err := snapshot.Append(*snapshotPath, snapshot.Snapshot{
+		Time:   runTime,
+		Values: values,
+	}); err != nil {
+		fatal("failed to take snapshot", err)
+	}
+}
+
+// openStore is used to open the metrics store selected by the
+// store flag, exiting if it can't be opened
+func openStore(db *gorm.DB) store.Store {
+	var (
+		s   store.Store
+		err error
+	)
+	switch *storeType {
+	case "postgres":
+		s, err = store.NewPostgres(db)
+	case "file":
+		s, err = store.NewFile(*storePath)
+	default:
+		err = fmt.Errorf("unknown store type %q", *storeType)
+	}
+	if err != nil {
+		fatal("failed to open metrics store", err)
+	}
+	return s
+}
+
+// record is used to persist the given points in the metrics
+// store, if recording is enabled. points without a time are
+// recorded at the start of the run, and points without a run
+// id are recorded under the id of the run.
+func record(db *gorm.DB, points ...store.Point) {
+	if !*recordMetrics || len(points) == 0 {
+		return
+	}
+	for i := range points {
+		if points[i].Time.IsZero() {
+			points[i].Time = runTime
+		}
+		if points[i].RunID == "" {
+			points[i].RunID = runID
+		}
+	}
+	s := openStore(db)
+	defer s.Close()
+	if err := s.Record(points...); err != nil {
+		fatal("failed to record metrics", err)
+	}
+}
+
+// metric is used to create a point for the metrics store,
+// with labels given as alternating keys and values
+func metric(name string, value float64, labels ...string) store.Point {
+	return metricAt(time.Time{}, name, value, labels...)
+}
+
+// metricAt is used to create a point observed at the given time
+func metricAt(t time.Time, name string, value float64, labels ...string) store.Point {
+	p := store.Point{Time: t, Name: name, Value: value}
+	if len(labels) > 0 {
+		p.Labels = make(map[string]string, len(labels)/2)
+		for i := 0; i+1 < len(labels); i += 2 {
+			p.Labels[labels[i]] = labels[i+1]
+		}
+	}
+	return p
+}
+
+// periodMetric is used to create a point holding the value of the whole
+// day, week or month starting at the given time, recorded under the period
+// run id so that runs covering the same period replace its value. ok is
+// false if the period isn't complete within the days flag, as it may have
+// started before the window or not have ended yet, and its partial value
+// would replace the complete value recorded by another run.
+func periodMetric(start time.Time, period, name string, value float64, labels ...string) (store.Point, bool) {
+	var end time.Time
+	switch period {
+	case "day":
+		end = start.AddDate(0, 0, 1)
+	case "week":
+		end = start.AddDate(0, 0, 7)
+	case "month":
+		end = start.AddDate(0, 1, 0)
+	}
+	if start.Before(since()) || end.After(runTime) {
+		return store.Point{}, false
+	}
+	p := metricAt(start, name, value, labels...)
+	p.RunID = store.PeriodRunID
+	return p, true
+}
+
+// histogramMetrics is used to create one point per histogram bucket,
+// labelled with the bucket, whose value is the bucket count
+func histogramMetrics(name string, h *stats.Histogram) []store.Point {
+	points := make([]store.Point, 0, len(h.Buckets))
+	for _, b := range h.Buckets {
+		points = append(points, metric(name, float64(b.Count), "bucket", b.Label))
+	}
+	return points
+}
+
+// valueMetrics is used to create a point for each of the given values
+func valueMetrics(values map[string]float64) []store.Point {
+	points := make([]store.Point, 0, len(values))
+	for name, value := range values {
+		points = append(points, metric(name, value))
+	}
+	return points
+}
+
+// parseLabels is used to parse the labels flag
+func parseLabels() map[string]string {
+	parsed := make(map[string]string)
+	if *labels == "" {
+		return parsed
+	}
+	for _, pair := range strings.Split(*labels, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			fmt.Printf("invalid label '%s', expected key=value\n", pair)
+			os.Exit(1)
+		}
+		parsed[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return parsed
+}
+
+// history is used to get the history of a metric over the given
+// number of days from the metrics store, filtered by the labels flag
+func history(db *gorm.DB, metric string, days int) []store.Point {
+	s := openStore(db)
+	defer s.Close()
+	points, err := store.History(s, metric, runTime.AddDate(0, 0, -days), runTime)
+	if err != nil {
+		fatal("failed to query metric history", err)
+	}
+	return store.Filter(points, parseLabels())
+}
+
+// parseTime is used to parse a date, such as 2019-01-31,
+// or an rfc3339 time, such as 2019-01-31T12:00:00Z
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// recorded is used to get the metrics recorded as of a reference, filtered by
+// the labels flag. the reference is a run id, or a date or time, which gives
+// the last value of every metric recorded by the end of the day or that time.
+func recorded(s store.Store, ref string) []store.Point {
+	var (
+		points []store.Point
+		err    error
+	)
+	if at, perr := parseTime(ref); perr == nil {
+		if _, perr := time.Parse("2006-01-02", ref); perr == nil {
+			at = at.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		points, err = store.Latest(s, at)
+	} else {
+		points, err = s.QueryRun(ref)
+	}
+	if err != nil {
+		fatal("failed to query metrics recorded as of "+ref, err)
+	}
+	points = store.Filter(points, parseLabels())
+	if len(points) == 0 {
+		fmt.Printf("no metrics were recorded as of '%s'\n", ref)
+		os.Exit(1)
+	}
+	return points
+}
+
+// projectRunway is used to project the runway of the given
+// metric history towards capacity, using the runway model
+func projectRunway(points []store.Point, capacity float64) (*forecast.Runway, error) {
+	m, err := forecast.ModelByName(*runwayModel)
+	if err != nil {
+		return nil, err
+	}
+	series, err := forecast.FromPoints(points)
+	if err != nil {
+		return nil, err
+	}
+	return forecast.ProjectRunway(m, series, capacity, *runwayHorizon)
+}
+
+// runwayLines formats a storage runway as report lines, along with an alert
+// if the earliest date it could be full is within the runway alert days
+func runwayLines(name string, r *forecast.Runway) (lines []string, alert string) {
+	lines = append(lines, fmt.Sprintf("%s is using %v of %v (%.2f%%)", name,
+		datasize.ByteSize(r.Used).HR(), datasize.ByteSize(r.Capacity).HR(), r.Used/r.Capacity*100))
+	for _, c := range r.Crossings {
+		switch {
+		case c.Days == 0:
+			lines = append(lines, fmt.Sprintf("%s has reached %.0f%% of capacity", name, c.Threshold*100))
+		case c.Days < 0 && c.Earliest < 0:
+			lines = append(lines, fmt.Sprintf("%s isn't projected to reach %.0f%% of capacity within %v days",
+				name, c.Threshold*100, *runwayHorizon))
+		case c.Days < 0:
+			lines = append(lines, fmt.Sprintf("%s could reach %.0f%% of capacity in %v days",
+				name, c.Threshold*100, c.Earliest))
+		default:
+			lines = append(lines, fmt.Sprintf("%s is projected to reach %.0f%% of capacity in %v days (%s), and could in %v days",
+				name, c.Threshold*100, c.Days, c.Date.Format("2006-01-02"), c.Earliest))
+		}
+	}
+	if full := r.Full(); full.Earliest >= 0 && full.Earliest < *runwayAlert {
+		alert = fmt.Sprintf("ALERT: %s could run out of storage in %v days, within the %v day alert threshold",
+			name, full.Earliest, *runwayAlert)
+	}
+	return lines, alert
+}
+
+// storageRunways is used to project the storage runway of the ipfs node, whose
+// capacity is given, and of each hosted network with history in the metrics store
+func storageRunways(db *gorm.DB, s store.Store, nodeCapacity float64) (lines, alerts []string) {
+	add := func(name string, points []store.Point, capacity float64) {
+		r, err := projectRunway(points, capacity)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("%s runway: %s", name, err))
+			return
+		}
+		runway, alert := runwayLines(name, r)
+		lines = append(lines, runway...)
+		if alert != "" {
+			alerts = append(alerts, alert)
+		}
+	}
+	from := runTime.AddDate(0, 0, -*forecastDays)
+	points, err := store.History(s, node.RepoSizeMetric, from, runTime)
+	if err != nil {
+		fatal("failed to query node storage history", err)
+	}
+	add("the ipfs node", points, nodeCapacity)
+	allocations, err := network.NewFarmer(db).DiskAllocations()
+	if err != nil {
+		fatal("failed to get network disk allocations", err)
+	}
+	names := make([]string, 0, len(allocations))
+	for name := range allocations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	networks, err := store.History(s, "networks.repo_size", from, runTime)
+	if err != nil {
+		fatal("failed to query network storage history", err)
+	}
+	for _, name := range names {
+		points := store.Filter(networks, map[string]string{"network": name})
+		if len(points) == 0 {
+			continue
+		}
+		add("network "+name, points, float64(allocations[name]))
+	}
+	return lines, alerts
+}
+
+// nodeCapacity returns the storage capacity of the ipfs node, from the
+// capacity flag if set, and otherwise from the node's storage max
+func nodeCapacity(m *node.Metrics) float64 {
+	if *capacityGB > 0 {
+		return *capacityGB * float64(datasize.GB.Bytes())
+	}
+	return float64(m.StorageMax)
+}
+
+// nodeLines formats ipfs node metrics as report lines
+func nodeLines(m *node.Metrics) []string {
+	return []string{
+		fmt.Sprintf("the ipfs node stores %v objects using %v of %v",
+			m.NumObjects, datasize.ByteSize(m.RepoSize).HR(), datasize.ByteSize(m.StorageMax).HR()),
+		fmt.Sprintf("the ipfs node has received %v and sent %v since it started",
+			datasize.ByteSize(m.TotalIn).HR(), datasize.ByteSize(m.TotalOut).HR()),
+		fmt.Sprintf("the ipfs node is receiving %v/s and sending %v/s",
+			datasize.ByteSize(m.RateIn).HR(), datasize.ByteSize(m.RateOut).HR()),
+		fmt.Sprintf("the ipfs node is connected to %v peers", m.Peers),
+	}
+}
+
+// dailyDeltas is used to get the day over day changes in the named metric
+// within the days flag, treating it as a counter if counter is set. when
+// recording, they're computed from the metrics store, so that they agree
+// with the history, charts and diffs read from it. the snapshot file is
+// only read by runs which don't record, as it needs no store to be set up.
+// ok is false if neither is available.
+func dailyDeltas(db *gorm.DB, name string, counter bool) (deltas []store.Point, ok bool) {
+	if *recordMetrics {
+		points := history(db, name, *days)
+		if counter {
+			return store.DailyIncreases(points), true
+		}
+		return store.DailyDeltas(points), true
+	}
+	if *snapshotPath == "" {
+		return nil, false
+	}
+	snapshots, err := snapshot.Load(*snapshotPath)
+	if err != nil {
+		fatal("failed to load snapshots", err)
+	}
+	changes := snapshot.DailyDeltas(snapshots, name)
+	if counter {
+		changes = snapshot.DailyIncreases(snapshots, name)
+	}
+	for _, d := range changes {
+		if !d.Day.Before(since()) {
+			deltas = append(deltas, metricAt(d.Day, name, d.Value))
+		}
+	}
+	return deltas, true
+}
+
+// lastDelta returns the most recent of the given deltas, or 0 if there are none
+func lastDelta(deltas []store.Point) uint64 {
+	if len(deltas) == 0 || deltas[len(deltas)-1].Value < 0 {
+		return 0
+	}
+	return uint64(deltas[len(deltas)-1].Value)
+}
+
+// since returns the start of the window covered by the days flag
+func since() time.Time {
+	return runTime.AddDate(0, 0, -*days)
+}
+
+// asOfCaveat describes the metrics which can't be reconstructed as of a past time
+const asOfCaveat = "ipfs node, cluster and network metrics are always live, " +
+	"columns updated in place such as credits, tiers, key counts and ipns sequences are read at their current values, " +
+	"and rows updated since are missing from activity, records updated and stale zone counts"
+
+// applyAsOf is used to wrap the action of each command, so that the as-of
+// flag, which may follow the command name, sets the run time before it runs.
+// metrics evaluated as of a past time aren't exact, so they're never
+// recorded, where they'd be read as history by charts, forecasts and diffs.
+func applyAsOf(cmds map[string]cmd.Cmd) {
+	for name, c := range cmds {
+		if action := c.Action; action != nil {
+			c.Action = func(cfg config.TemporalConfig, args map[string]string) {
+				if *asOf != "" {
+					at, err := parseTime(*asOf)
+					if err != nil {
+						fatal("failed to parse as-of time", err)
+					}
+					if *recordMetrics || *snapshotPath != "" {
+						fmt.Println("metrics evaluated as of a past time can't be recorded or snapshotted")
+						os.Exit(1)
+					}
+					runTime = at
+				}
+				action(cfg, args)
+			}
+		}
+		applyAsOf(c.Children)
+		cmds[name] = c
+	}
+}
+
+// fatal is used to print an error message and exit
+func fatal(msg string, err error) {
+	fmt.Println(msg, err.Error())
+	os.Exit(1)
+}
+
 var commands = map[string]cmd.Cmd{
 	"user": {
 		Blurb:         "User based metrics",
@@ -91,231 +727,1060 @@ var commands = map[string]cmd.Cmd{
 				Blurb:       "Registered users",
 				Description: "Used to get the number of registered users",
 				Action: func(cfg config.TemporalConfig, args map[string]string) {
-					db, err := database.New(&cfg, database.Options{
-						SSLModeDisable: *dbNoSSL,
-						RunMigrations:  *dbMigrate,
-					})
+					db := openDatabase(cfg)
+					users, err := user.NewFarmer(db).RegisteredUsers()
 					if err != nil {
-						fmt.Println("failed to initialize database connection", err.Error())
-						os.Exit(1)
-					}
-					uf := user.NewFarmer(db.DB)
-					users, err := uf.RegisteredUsers()
-					if err != nil {
-						fmt.Println("failed to get registered users", err.Error())
-						os.Exit(1)
-					}
-					numberOfUsers := len(users)
-					msg := fmt.Sprintf("there are %v total registered users", numberOfUsers)
-					fmt.Println(msg)
-					if *sendEmail {
-						mm, err := mail.NewManager(&cfg, db.DB)
-						if err != nil {
-							fmt.Println("failed to initialize mail manager", err.Error())
-							os.Exit(1)
-						}
-						if _, err := mm.SendEmail(
-							"registered users report",
-							msg,
-							"text/html",
-							*recipientName,
-							*emailRecipient,
-						); err != nil {
-							fmt.Println("failed to send email report", err.Error())
-							os.Exit(1)
-						}
+						fatal("failed to get registered users", err)
 					}
+					record(db, metric("users.registered", float64(len(users))))
+					report(cfg, db, "registered users report", fmt.Sprintf("there are %v total registered users", len(users)))
 				},
 			},
 			"free": {
 				Blurb:       "Free users",
 				Description: "Used to get the number of free users",
 				Action: func(cfg config.TemporalConfig, args map[string]string) {
-					db, err := database.New(&cfg, database.Options{
-						SSLModeDisable: *dbNoSSL,
-						RunMigrations:  *dbMigrate,
-					})
+					db := openDatabase(cfg)
+					users, err := user.NewFarmer(db).FreeUsers()
 					if err != nil {
-						fmt.Println("failed to initialize database connection", err.Error())
-						os.Exit(1)
+						fatal("failed to get free users", err)
 					}
-					uf := user.NewFarmer(db.DB)
-					users, err := uf.FreeUsers()
+					record(db, metric("users.free", float64(len(users))))
+					report(cfg, db, "free users report", fmt.Sprintf("there are %v total free users", len(users)))
+				},
+			},
+			"paid": {
+				Blurb:       "Paid users",
+				Description: "Used to get the number of paid users",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					users, err := user.NewFarmer(db).PaidUsers()
 					if err != nil {
-						fmt.Println("failed to get free users", err.Error())
-						os.Exit(1)
+						fatal("failed to get paid users", err)
 					}
-					numberOfUsers := len(users)
-					msg := fmt.Sprintf("there are %v total free users", numberOfUsers)
-					fmt.Println(msg)
-					if *sendEmail {
-						mm, err := mail.NewManager(&cfg, db.DB)
-						if err != nil {
-							fmt.Println("failed to initialize mail manager", err.Error())
-							os.Exit(1)
+					record(db, metric("users.paid", float64(len(users))))
+					report(cfg, db, "paid users report", fmt.Sprintf("there are %v total paid users", len(users)))
+				},
+			},
+		},
+	},
+	"upload": {
+		Blurb:         "Upload based metrics",
+		Description:   "Allows for gathering of upload based metrics (number of uploads, type, etc...)",
+		ChildRequired: true,
+		Children: map[string]cmd.Cmd{
+			"count": {
+				Blurb:       "Upload count",
+				Description: "Gets the total number of uploads",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					num, err := upload.NewFarmer(db, openIPFS(cfg)).NumberOfUploads()
+					if err != nil {
+						fatal("failed to get number of uploads", err)
+					}
+					record(db, metric("uploads.total", float64(num)))
+					report(cfg, db, "upload count report", fmt.Sprintf("there are %v total uploads", num))
+				},
+			},
+			"size": {
+				Blurb:       "Upload Size Average",
+				Description: "Gets the average size of uploads",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					size, err := upload.NewFarmer(db, openIPFS(cfg)).AverageUploadSize(*unique)
+					if err != nil {
+						fatal("failed to get upload size average", err)
+					}
+					var uniqueMessage string
+					if *unique {
+						uniqueMessage = "unique"
+					} else {
+						uniqueMessage = "non unique"
+					}
+					record(db, metric("uploads.average_size_gb", size, "unique", fmt.Sprint(*unique)))
+					report(cfg, db, "upload size report",
+						fmt.Sprintf("the %s average size of uploads is %v gigabytes", uniqueMessage, size))
+				},
+			},
+			"concentration": {
+				Blurb:       "Upload concentration",
+				Description: "Gets the distribution of uploads and stored bytes across accounts",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					uf := upload.NewFarmer(db, openIPFS(cfg))
+					c, err := uf.UploadConcentration()
+					if err != nil {
+						fatal("failed to get upload concentration", err)
+					}
+					points := []store.Point{
+						metric("uploads.accounts", float64(c.Users)),
+						metric("uploads.bytes", float64(c.TotalBytes)),
+						metric("uploads.gini", c.Gini),
+						metric("uploads.top1_share", c.Top1PercentShare),
+						metric("uploads.top10_share", c.Top10PercentShare),
+					}
+					points = append(points, histogramMetrics("uploads.per_account", c.UploadsPerUser)...)
+					points = append(points, histogramMetrics("uploads.bytes_per_account", c.BytesPerUser)...)
+					record(db, points...)
+					report(cfg, db, "upload concentration report",
+						fmt.Sprintf("there are %v accounts storing %v", c.Users, datasize.ByteSize(c.TotalBytes).HR()),
+						fmt.Sprintf("uploads per account: %s", c.UploadsPerUser),
+						fmt.Sprintf("bytes per account: %s", c.BytesPerUser),
+						fmt.Sprintf("gini coefficient of bytes per account: %.4f", c.Gini),
+						fmt.Sprintf("share of bytes stored by the top 1%% of accounts: %.2f%%", c.Top1PercentShare*100),
+						fmt.Sprintf("share of bytes stored by the top 10%% of accounts: %.2f%%", c.Top10PercentShare*100),
+					)
+				},
+			},
+			"replication": {
+				Blurb:       "Content replication",
+				Description: "Gets how often the same content is uploaded by multiple accounts and networks",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					uf := upload.NewFarmer(db, openIPFS(cfg))
+					r, err := uf.ContentReplication()
+					if err != nil {
+						fatal("failed to get content replication", err)
+					}
+					points := []store.Point{
+						metric("uploads.cids", float64(r.CIDs)),
+						metric("uploads.shared_cids", float64(r.SharedCIDs)),
+						metric("uploads.bytes_saved", float64(r.BytesSaved)),
+					}
+					points = append(points, histogramMetrics("uploads.accounts_per_cid", r.UsersPerCID)...)
+					points = append(points, histogramMetrics("uploads.networks_per_cid", r.NetworksPerCID)...)
+					record(db, points...)
+					report(cfg, db, "content replication report",
+						fmt.Sprintf("there are %v distinct content hashes, %v of which are uploaded more than once", r.CIDs, r.SharedCIDs),
+						fmt.Sprintf("accounts per content hash: %s", r.UsersPerCID),
+						fmt.Sprintf("networks per content hash: %s", r.NetworksPerCID),
+						fmt.Sprintf("sharing content saves %v of storage", datasize.ByteSize(r.BytesSaved).HR()),
+					)
+				},
+			},
+		},
+	},
+	"ipns": {
+		Blurb:         "IPNS based metrics",
+		Description:   "Allows for gathering of ipns based metrics (records published, lifetimes, etc...)",
+		ChildRequired: true,
+		Children: map[string]cmd.Cmd{
+			"created": {
+				Blurb:       "Records created per day",
+				Description: "Gets the number of ipns records created each day",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					counts, err := ipns.NewFarmer(db).RecordsCreatedPerDay(since())
+					if err != nil {
+						fatal("failed to get ipns records created per day", err)
+					}
+					lines := []string{fmt.Sprintf("ipns records created per day over the last %v days", *days)}
+					points := make([]store.Point, 0, len(counts))
+					for _, c := range counts {
+						lines = append(lines, fmt.Sprintf("%s: %v", c.Day.Format("2006-01-02"), c.Count))
+						if p, ok := periodMetric(c.Day, "day", "ipns.records_created", float64(c.Count)); ok {
+							points = append(points, p)
 						}
-						if _, err := mm.SendEmail(
-							"free users report",
-							msg,
-							"text/html",
-							*recipientName,
-							*emailRecipient,
-						); err != nil {
-							fmt.Println("failed to send email report", err.Error())
-							os.Exit(1)
+					}
+					record(db, points...)
+					report(cfg, db, "ipns records created report", lines...)
+				},
+			},
+			"updated": {
+				Blurb:       "Records updated per day",
+				Description: "Gets the number of ipns records updated each day",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					counts, err := ipns.NewFarmer(db).RecordsUpdatedPerDay(since())
+					if err != nil {
+						fatal("failed to get ipns records updated per day", err)
+					}
+					lines := []string{fmt.Sprintf("ipns records last updated per day over the last %v days", *days)}
+					points := make([]store.Point, 0, len(counts))
+					for _, c := range counts {
+						lines = append(lines, fmt.Sprintf("%s: %v", c.Day.Format("2006-01-02"), c.Count))
+						if p, ok := periodMetric(c.Day, "day", "ipns.records_updated", float64(c.Count)); ok {
+							points = append(points, p)
 						}
 					}
+					record(db, points...)
+					report(cfg, db, "ipns records updated report", lines...)
 				},
 			},
-			"paid": {
-				Blurb:       "Paid users",
-				Description: "Used to get the number of paid users",
+			"networks": {
+				Blurb:       "Records by network",
+				Description: "Gets the number of ipns records published on each network",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					networks, err := ipns.NewFarmer(db).RecordsByNetwork()
+					if err != nil {
+						fatal("failed to get ipns records by network", err)
+					}
+					names := make([]string, 0, len(networks))
+					for name := range networks {
+						names = append(names, name)
+					}
+					sort.Strings(names)
+					lines := []string{fmt.Sprintf("ipns records are published on %v networks", len(networks))}
+					points := make([]store.Point, 0, len(names))
+					for _, name := range names {
+						lines = append(lines, fmt.Sprintf("%s: %v", name, networks[name]))
+						points = append(points, metric("ipns.records", float64(networks[name]), "network", name))
+					}
+					record(db, points...)
+					report(cfg, db, "ipns records by network report", lines...)
+				},
+			},
+			"durations": {
+				Blurb:       "Record lifetimes and ttls",
+				Description: "Gets the distribution of ipns record lifetimes and ttls, and the number of likely expired records",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					d, err := ipns.NewFarmer(db).RecordDurations()
+					if err != nil {
+						fatal("failed to get ipns record durations", err)
+					}
+					points := []store.Point{
+						metric("ipns.expired", float64(d.Expired)),
+						metric("ipns.invalid", float64(d.Invalid)),
+					}
+					points = append(points, histogramMetrics("ipns.lifetimes", d.LifeTimes)...)
+					points = append(points, histogramMetrics("ipns.ttls", d.TTLs)...)
+					record(db, points...)
+					report(cfg, db, "ipns record durations report",
+						fmt.Sprintf("lifetimes: %s", d.LifeTimes),
+						fmt.Sprintf("ttls: %s", d.TTLs),
+						fmt.Sprintf("there are %v likely expired records", d.Expired),
+						fmt.Sprintf("there are %v records with invalid durations", d.Invalid),
+					)
+				},
+			},
+			"probe": {
+				Blurb:       "Record resolution probe",
+				Description: "Resolves a sample of public ipns records and compares them with their stored ipfs hash",
 				Action: func(cfg config.TemporalConfig, args map[string]string) {
-					db, err := database.New(&cfg, database.Options{
-						SSLModeDisable: *dbNoSSL,
-						RunMigrations:  *dbMigrate,
+					db := openDatabase(cfg)
+					r, err := ipns.NewFarmer(db).Probe(openIPFS(cfg), ipns.ProbeOptions{
+						Sample:      *probeSample,
+						Concurrency: *probeConcurrency,
+						Timeout:     *probeTimeout,
 					})
 					if err != nil {
-						fmt.Println("failed to initialize database connection", err.Error())
-						os.Exit(1)
+						fatal("failed to probe ipns records", err)
+					}
+					record(db,
+						metric("ipns.probe.probed", float64(r.Probed)),
+						metric("ipns.probe.match_rate", r.MatchRate()),
+						metric("ipns.probe.stale_rate", r.StaleRate()),
+						metric("ipns.probe.unresolvable_rate", r.UnresolvableRate()),
+					)
+					report(cfg, db, "ipns resolution probe report",
+						fmt.Sprintf("probed %v ipns records", r.Probed),
+						fmt.Sprintf("matching: %.2f%%", r.MatchRate()*100),
+						fmt.Sprintf("stale: %.2f%%", r.StaleRate()*100),
+						fmt.Sprintf("unresolvable: %.2f%%", r.UnresolvableRate()*100),
+					)
+				},
+			},
+		},
+	},
+	"keys": {
+		Blurb:         "Key based metrics",
+		Description:   "Allows for gathering of key management metrics (keys created, keys per account, etc...)",
+		ChildRequired: true,
+		Children: map[string]cmd.Cmd{
+			"summary": {
+				Blurb:       "Key summary",
+				Description: "Gets the number of keys held by accounts, and flags inconsistent key counters",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					ks, err := keys.NewFarmer(db).KeySummary()
+					if err != nil {
+						fatal("failed to get key summary", err)
+					}
+					takeSnapshot(map[string]float64{"keys.total": float64(ks.TotalKeys)})
+					points := []store.Point{
+						metric("keys.total", float64(ks.TotalKeys)),
+						metric("keys.at_limit", float64(ks.AtLimit)),
+						metric("keys.inconsistent", float64(ks.Inconsistent)),
+						metric("keys.mismatched_arrays", float64(ks.MismatchedArrays)),
 					}
-					uf := user.NewFarmer(db.DB)
-					users, err := uf.FreeUsers()
+					for tier, count := range ks.KeysPerTier {
+						points = append(points, metric("keys.per_tier", float64(count), "tier", string(tier)))
+					}
+					points = append(points, histogramMetrics("keys.per_account", ks.KeysPerUser)...)
+					record(db, points...)
+					report(cfg, db, "key summary report",
+						fmt.Sprintf("there are %v total keys", ks.TotalKeys),
+						fmt.Sprintf("keys per account: %s", ks.KeysPerUser),
+						fmt.Sprintf("keys held by free accounts: %v", ks.KeysPerTier[models.Free]),
+						fmt.Sprintf("keys held by paid accounts: %v", ks.KeysPerTier[models.Paid]),
+						fmt.Sprintf("keys held by partner accounts: %v", ks.KeysPerTier[models.Partner]),
+						fmt.Sprintf("there are %v accounts at their key limit", ks.AtLimit),
+						fmt.Sprintf("there are %v accounts whose key counter disagrees with their keys", ks.Inconsistent),
+						fmt.Sprintf("there are %v accounts whose key names disagree with their key ids", ks.MismatchedArrays),
+					)
+				},
+			},
+			"created": {
+				Blurb:       "Keys created per day",
+				Description: "Gets the number of keys created each day, using the key totals recorded by previous runs",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					ks, err := keys.NewFarmer(db).KeySummary()
 					if err != nil {
-						fmt.Println("failed to get plus users", err.Error())
+						fatal("failed to get key summary", err)
+					}
+					takeSnapshot(map[string]float64{"keys.total": float64(ks.TotalKeys)})
+					record(db, metric("keys.total", float64(ks.TotalKeys)))
+					deltas, ok := dailyDeltas(db, "keys.total", false)
+					if !ok {
+						fmt.Println("record or snapshot.path must be set to compute keys created per day")
 						os.Exit(1)
 					}
-					numberOfUsers := len(users)
-					msg := fmt.Sprintf("there are %v total plus users", numberOfUsers)
-					fmt.Println(msg)
-					if *sendEmail {
-						mm, err := mail.NewManager(&cfg, db.DB)
-						if err != nil {
-							fmt.Println("failed to initialize mail manager", err.Error())
-							os.Exit(1)
+					lines := []string{"keys created per day"}
+					var points []store.Point
+					for _, d := range deltas {
+						lines = append(lines, fmt.Sprintf("%s: %v", d.Time.Format("2006-01-02"), d.Value))
+						if p, ok := periodMetric(d.Time, "day", "keys.created", d.Value); ok {
+							points = append(points, p)
 						}
-						if _, err := mm.SendEmail(
-							"plus users report",
-							msg,
-							"text/html",
-							*recipientName,
-							*emailRecipient,
-						); err != nil {
-							fmt.Println("failed to send email report", err.Error())
-							os.Exit(1)
+					}
+					record(db, points...)
+					report(cfg, db, "keys created report", lines...)
+				},
+			},
+		},
+	},
+	"payment": {
+		Blurb:         "Payment based metrics",
+		Description:   "Allows for gathering of payment based metrics (revenue, payment types, paying accounts, etc...)",
+		ChildRequired: true,
+		Children: map[string]cmd.Cmd{
+			"revenue": {
+				Blurb:       "Confirmed revenue",
+				Description: "Gets the confirmed revenue in USD of each period, as set by the period flag",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					revenue, err := payment.NewFarmer(db).ConfirmedRevenue(*period, since())
+					if err != nil {
+						fatal("failed to get confirmed revenue", err)
+					}
+					var total float64
+					lines := []string{fmt.Sprintf("confirmed revenue per %s over the last %v days", *period, *days)}
+					points := make([]store.Point, 0, len(revenue))
+					for _, r := range revenue {
+						total += r.USD
+						lines = append(lines, fmt.Sprintf("%s: $%.2f", r.Period.Format("2006-01-02"), r.USD))
+						if p, ok := periodMetric(r.Period, *period, "payments.revenue", r.USD, "period", *period); ok {
+							points = append(points, p)
 						}
 					}
+					record(db, points...)
+					lines = append(lines, fmt.Sprintf("total: $%.2f", total))
+					report(cfg, db, "confirmed revenue report", lines...)
+				},
+			},
+			"shares": {
+				Blurb:       "Revenue shares",
+				Description: "Gets the share of confirmed revenue by payment type and blockchain",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					pf := payment.NewFarmer(db)
+					types, err := pf.RevenueByType()
+					if err != nil {
+						fatal("failed to get revenue by payment type", err)
+					}
+					chains, err := pf.RevenueByBlockchain()
+					if err != nil {
+						fatal("failed to get revenue by blockchain", err)
+					}
+					lines := []string{"confirmed revenue by payment type"}
+					var points []store.Point
+					for _, s := range types {
+						lines = append(lines, fmt.Sprintf("%s: $%.2f (%.2f%%)", s.Name, s.USD, s.Share*100))
+						points = append(points, metric("payments.revenue_by_type", s.USD, "type", s.Name))
+					}
+					lines = append(lines, "confirmed revenue by blockchain")
+					for _, s := range chains {
+						lines = append(lines, fmt.Sprintf("%s: $%.2f (%.2f%%)", s.Name, s.USD, s.Share*100))
+						points = append(points, metric("payments.revenue_by_blockchain", s.USD, "blockchain", s.Name))
+					}
+					record(db, points...)
+					report(cfg, db, "revenue share report", lines...)
+				},
+			},
+			"accounts": {
+				Blurb:       "Paying accounts",
+				Description: "Gets the number of accounts with at least one confirmed payment",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					accounts, err := payment.NewFarmer(db).PayingAccounts()
+					if err != nil {
+						fatal("failed to get paying accounts", err)
+					}
+					record(db, metric("payments.paying_accounts", float64(accounts)))
+					report(cfg, db, "paying accounts report",
+						fmt.Sprintf("there are %v paying accounts", accounts))
+				},
+			},
+			"aging": {
+				Blurb:       "Unconfirmed payment aging",
+				Description: "Gets the number and USD value of unconfirmed payments, bucketed by age",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					aging, err := payment.NewFarmer(db).UnconfirmedAging()
+					if err != nil {
+						fatal("failed to get unconfirmed payment aging", err)
+					}
+					lines := []string{fmt.Sprintf("there are %v unconfirmed payments", aging.Total())}
+					var points []store.Point
+					for _, b := range aging.Buckets {
+						lines = append(lines, fmt.Sprintf("%s: %v payments worth $%.2f", b.Label, b.Count, b.Sum))
+						points = append(points,
+							metric("payments.unconfirmed", float64(b.Count), "age", b.Label),
+							metric("payments.unconfirmed_usd", b.Sum, "age", b.Label),
+						)
+					}
+					record(db, points...)
+					report(cfg, db, "unconfirmed payment aging report", lines...)
+				},
+			},
+			"latency": {
+				Blurb:       "Payment confirmation latency",
+				Description: "Gets the distribution of time taken to confirm payments on each blockchain",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					latencies, err := payment.NewFarmer(db).ConfirmationLatency()
+					if err != nil {
+						fatal("failed to get payment confirmation latency", err)
+					}
+					lines := []string{"payment confirmation latency by blockchain"}
+					var points []store.Point
+					for _, l := range latencies {
+						lines = append(lines,
+							fmt.Sprintf("%s: %v confirmed, median %s, p95 %s", l.Blockchain, l.Confirmed, l.Median, l.P95),
+							fmt.Sprintf("%s distribution: %s", l.Blockchain, l.Distribution),
+						)
+						points = append(points,
+							metric("payments.confirmation_latency_median_seconds", l.Median.Seconds(), "blockchain", l.Blockchain),
+							metric("payments.confirmation_latency_p95_seconds", l.P95.Seconds(), "blockchain", l.Blockchain),
+						)
+					}
+					record(db, points...)
+					report(cfg, db, "payment confirmation latency report", lines...)
+				},
+			},
+			"ledger": {
+				Blurb:       "Credits ledger reconciliation",
+				Description: "Compares account credits with confirmed payments, and gets the total outstanding credit liability",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					l, err := payment.NewFarmer(db).ReconcileCredits()
+					if err != nil {
+						fatal("failed to reconcile credits", err)
+					}
+					points := []store.Point{
+						metric("payments.credit_liability", l.Liability),
+						metric("payments.credit_accounts", float64(l.Accounts)),
+						metric("payments.credits_matching", float64(l.Matching)),
+					}
+					points = append(points, histogramMetrics("payments.credits_above", l.Above)...)
+					points = append(points, histogramMetrics("payments.credits_below", l.Below)...)
+					record(db, points...)
+					report(cfg, db, "credits ledger report",
+						fmt.Sprintf("total outstanding credit liability across %v accounts: $%.2f", l.Accounts, l.Liability),
+						fmt.Sprintf("there are %v accounts whose credits match their payments", l.Matching),
+						fmt.Sprintf("there are %v accounts with more credits than payments: %s", l.Above.Total(), l.Above),
+						fmt.Sprintf("there are %v accounts with less credits than payments: %s", l.Below.Total(), l.Below),
+					)
 				},
 			},
 		},
 	},
-	"upload": {
-		Blurb:         "Upload based metrics",
-		Description:   "Allows for gathering of upload based metrics (number of uploads, type, etc...)",
+	"revenue": {
+		Blurb:         "Revenue estimates",
+		Description:   "Allows for estimating revenue from usage and tier pricing",
 		ChildRequired: true,
 		Children: map[string]cmd.Cmd{
-			"count": {
-				Blurb:       "Upload count",
-				Description: "Gets the total number of uploads",
+			"estimate": {
+				Blurb:       "Estimated storage revenue",
+				Description: "Estimates this month's storage revenue per tier, along with ARPU, ARPPU and run rate",
 				Action: func(cfg config.TemporalConfig, args map[string]string) {
-					db, err := database.New(&cfg, database.Options{
-						SSLModeDisable: *dbNoSSL,
-						RunMigrations:  *dbMigrate,
-					})
+					db := openDatabase(cfg)
+					e, err := revenue.NewFarmer(db).EstimateRevenue()
 					if err != nil {
-						fmt.Println("failed to initialize database connection", err.Error())
-						os.Exit(1)
+						fatal("failed to estimate revenue", err)
 					}
-					ipfs, err := rtfs.NewManager(
-						cfg.IPFS.APIConnection.Host+":"+cfg.IPFS.APIConnection.Port,
-						"", 1*time.Minute,
+					lines := []string{fmt.Sprintf("estimated month to date storage revenue: $%.2f", e.Revenue)}
+					points := []store.Point{
+						metric("revenue.estimated_total", e.Revenue),
+						metric("revenue.arpu", e.ARPU),
+						metric("revenue.arppu", e.ARPPU),
+						metric("revenue.run_rate", e.RunRate),
+					}
+					for _, t := range e.Tiers {
+						lines = append(lines, fmt.Sprintf("%s tier: %v accounts using %v, $%.2f",
+							t.Tier, t.Accounts, datasize.ByteSize(t.Bytes).HR(), t.Revenue))
+						points = append(points, metric("revenue.estimated", t.Revenue, "tier", string(t.Tier)))
+					}
+					record(db, points...)
+					lines = append(lines,
+						fmt.Sprintf("ARPU: $%.4f", e.ARPU),
+						fmt.Sprintf("ARPPU across %v paying accounts: $%.4f", e.PayingAccounts, e.ARPPU),
+						fmt.Sprintf("monthly run rate: $%.2f", e.RunRate),
 					)
+					report(cfg, db, "estimated revenue report", lines...)
+				},
+			},
+			"deferred": {
+				Blurb:       "Deferred storage revenue",
+				Description: "Gets the prepaid storage obligation remaining in each month until uploads are garbage collected",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					s, err := revenue.NewFarmer(db).DeferredRevenue(upload.NewFarmer(db, openIPFS(cfg)))
 					if err != nil {
-						fmt.Println("failed to open ipfs api connection", err.Error())
-						os.Exit(1)
+						fatal("failed to get deferred revenue", err)
+					}
+					lines := []string{fmt.Sprintf("remaining prepaid storage obligation for %v uploads: $%.2f", s.Uploads, s.Total)}
+					points := []store.Point{metric("revenue.deferred_total", s.Total)}
+					for _, m := range s.Months {
+						lines = append(lines, fmt.Sprintf("%s: $%.2f", m.Month.Format("2006-01"), m.USD))
+						points = append(points, metric("revenue.deferred", m.USD, "month", m.Month.Format("2006-01")))
 					}
-					uf := upload.NewFarmer(db.DB, ipfs)
-					num, err := uf.NumberOfUploads()
+					record(db, points...)
+					report(cfg, db, "deferred revenue report", lines...)
+				},
+			},
+		},
+	},
+	"tns": {
+		Blurb:         "TNS based metrics",
+		Description:   "Allows for gathering of temporal name service metrics (zones, records, etc...)",
+		ChildRequired: true,
+		Children: map[string]cmd.Cmd{
+			"zones": {
+				Blurb: "Zone summary",
+				Description: "Gets the number of zones, records per zone, and zones which haven't been touched at all within the days flag. " +
+					"zones are touched by any hash change or record added or updated, so this doesn't tell whether their published hash changed.",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					tf := tns.NewFarmer(db)
+					total, err := tf.TotalZones()
 					if err != nil {
-						fmt.Println("failed to get number of uploads", err.Error())
-						os.Exit(1)
+						fatal("failed to get total zones", err)
 					}
-					msg := fmt.Sprintf("there are %v total uploads", num)
-					fmt.Println(msg)
-					if *sendEmail {
-						mm, err := mail.NewManager(&cfg, db.DB)
-						if err != nil {
-							fmt.Println("failed to initialize mail manager", err.Error())
-							os.Exit(1)
+					records, err := tf.RecordsPerZone()
+					if err != nil {
+						fatal("failed to get records per zone", err)
+					}
+					stale, err := tf.StaleZones(since())
+					if err != nil {
+						fatal("failed to get stale zones", err)
+					}
+					points := []store.Point{
+						metric("tns.zones", float64(total)),
+						metric("tns.stale_zones", float64(stale)),
+					}
+					points = append(points, histogramMetrics("tns.records_per_zone", records)...)
+					record(db, points...)
+					report(cfg, db, "tns zones report",
+						fmt.Sprintf("there are %v total zones", total),
+						fmt.Sprintf("records per zone: %s", records),
+						fmt.Sprintf("there are %v zones which haven't been touched at all in %v days "+
+							"(no hash change, and no record added or updated); whether a hash alone changed isn't tracked", stale, *days),
+					)
+				},
+			},
+			"created": {
+				Blurb:       "Zones created",
+				Description: "Gets the number of zones created in each period, as set by the period flag",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					counts, err := tns.NewFarmer(db).ZonesCreated(*period, since())
+					if err != nil {
+						fatal("failed to get zones created", err)
+					}
+					lines := []string{fmt.Sprintf("zones created per %s over the last %v days", *period, *days)}
+					points := make([]store.Point, 0, len(counts))
+					for _, c := range counts {
+						lines = append(lines, fmt.Sprintf("%s: %v", c.Period.Format("2006-01-02"), c.Count))
+						if p, ok := periodMetric(c.Period, *period, "tns.zones_created", float64(c.Count), "period", *period); ok {
+							points = append(points, p)
 						}
-						if _, err := mm.SendEmail(
-							"upload count report",
-							msg,
-							"text/html",
-							*recipientName,
-							*emailRecipient,
-						); err != nil {
-							fmt.Println("failed to send email report", err.Error())
-							os.Exit(1)
+					}
+					record(db, points...)
+					report(cfg, db, "tns zones created report", lines...)
+				},
+			},
+		},
+	},
+	"network": {
+		Blurb:         "Hosted network metrics",
+		Description:   "Allows for gathering of hosted private network metrics (fleet state, resources, etc...)",
+		ChildRequired: true,
+		Children: map[string]cmd.Cmd{
+			"fleet": {
+				Blurb:       "Network fleet summary",
+				Description: "Gets the state, allocated resources, and membership of hosted networks",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					fleet, err := network.NewFarmer(db).FleetSummary()
+					if err != nil {
+						fatal("failed to get network fleet summary", err)
+					}
+					points := []store.Point{
+						metric("networks.total", float64(fleet.Total)),
+						metric("networks.online", float64(fleet.Online)),
+						metric("networks.offline", float64(fleet.Offline)),
+						metric("networks.disabled", float64(fleet.Disabled)),
+						metric("networks.public_gateways", float64(fleet.PublicGateways)),
+						metric("networks.allocated_cpus", float64(fleet.Allocated.CPUs)),
+						metric("networks.allocated_disk_gb", float64(fleet.Allocated.DiskGB)),
+						metric("networks.allocated_memory_gb", float64(fleet.Allocated.MemoryGB)),
+					}
+					points = append(points, histogramMetrics("networks.users_per_network", fleet.UsersPerNetwork)...)
+					points = append(points, histogramMetrics("networks.owners_per_network", fleet.OwnersPerNetwork)...)
+					record(db, points...)
+					report(cfg, db, "network fleet report",
+						fmt.Sprintf("there are %v hosted networks: %v online, %v offline, %v disabled",
+							fleet.Total, fleet.Online, fleet.Offline, fleet.Disabled),
+						fmt.Sprintf("there are %v networks with a public gateway", fleet.PublicGateways),
+						fmt.Sprintf("allocated to all networks: %v cpus, %vGB disk, %vGB memory",
+							fleet.Allocated.CPUs, fleet.Allocated.DiskGB, fleet.Allocated.MemoryGB),
+						fmt.Sprintf("allocated to online networks: %v cpus, %vGB disk, %vGB memory",
+							fleet.AllocatedOnline.CPUs, fleet.AllocatedOnline.DiskGB, fleet.AllocatedOnline.MemoryGB),
+						fmt.Sprintf("users per network: %s", fleet.UsersPerNetwork),
+						fmt.Sprintf("owners per network: %s", fleet.OwnersPerNetwork),
+					)
+				},
+			},
+			"utilization": {
+				Blurb:       "Live network utilization",
+				Description: "Queries the node of each online network for its disk usage and peers, compared to its allocation",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					address := *networkAPI
+					if address == "" {
+						address = cfg.Nexus.Host + ":" + cfg.Nexus.Delegator.Port + "/network/%s"
+					}
+					u, err := network.NewFarmer(db).LiveUtilization(func(name string) (rtfs.Manager, error) {
+						return rtfs.NewManager(fmt.Sprintf(address, name), "", *networkTimeout)
+					}, *networkTimeout)
+					if err != nil {
+						fatal("failed to get network utilization", err)
+					}
+					lines := []string{fmt.Sprintf("%v online networks: %v over-utilized, %v under-utilized, %v unreachable",
+						len(u.Nodes), u.Over, u.Under, u.Unreachable)}
+					points := []store.Point{
+						metric("networks.over_utilized", float64(u.Over)),
+						metric("networks.under_utilized", float64(u.Under)),
+						metric("networks.unreachable", float64(u.Unreachable)),
+					}
+					for _, n := range u.Nodes {
+						if n.Err != nil {
+							lines = append(lines, fmt.Sprintf("%s: unreachable (%s)", n.Network, n.Err))
+							continue
 						}
+						lines = append(lines, fmt.Sprintf("%s: %v of %vGB used (%.2f%%), %v peers",
+							n.Network, datasize.ByteSize(n.RepoSize).HR(), n.AllocatedDiskGB, n.Utilization*100, n.Peers))
+						points = append(points,
+							metric("networks.repo_size", float64(n.RepoSize), "network", n.Network),
+							metric("networks.utilization", n.Utilization, "network", n.Network),
+							metric("networks.peers", float64(n.Peers), "network", n.Network),
+						)
 					}
+					record(db, points...)
+					report(cfg, db, "network utilization report", lines...)
 				},
 			},
-			"size": {
-				Blurb:       "Upload Size Average",
-				Description: "Gets the average size of uploads",
+		},
+	},
+	"node": {
+		Blurb:         "IPFS node metrics",
+		Description:   "Allows for gathering of ipfs node metrics (repo size, bandwidth, peers, etc...)",
+		ChildRequired: true,
+		Children: map[string]cmd.Cmd{
+			"stats": {
+				Blurb:       "Node statistics",
+				Description: "Gets the repo, bandwidth and swarm statistics of temporal's ipfs node",
 				Action: func(cfg config.TemporalConfig, args map[string]string) {
-					db, err := database.New(&cfg, database.Options{
-						SSLModeDisable: *dbNoSSL,
-						RunMigrations:  *dbMigrate,
-					})
+					db := openDatabase(cfg)
+					m, err := node.NewFarmer(openIPFS(cfg)).Collect(ctx)
 					if err != nil {
-						fmt.Println("failed to initialize database connection", err.Error())
-						os.Exit(1)
+						fatal("failed to collect node metrics", err)
 					}
-					ipfs, err := rtfs.NewManager(
-						cfg.IPFS.APIConnection.Host+":"+cfg.IPFS.APIConnection.Port,
-						"", 1*time.Minute,
+					takeSnapshot(m.Values())
+					record(db, valueMetrics(m.Values())...)
+					report(cfg, db, "ipfs node report", nodeLines(m)...)
+				},
+			},
+			"runway": {
+				Blurb:       "Storage runway",
+				Description: "Projects when the ipfs node, and each hosted network, will reach 80%, 90% and 100% of its storage capacity",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					m, err := node.NewFarmer(openIPFS(cfg)).Collect(ctx)
+					if err != nil {
+						fatal("failed to collect node metrics", err)
+					}
+					s := openStore(db)
+					lines, alerts := storageRunways(db, s, nodeCapacity(m))
+					s.Close()
+					report(cfg, db, "storage runway report", append(alerts, lines...)...)
+				},
+			},
+		},
+	},
+	"cluster": {
+		Blurb:         "IPFS cluster metrics",
+		Description:   "Allows for gathering of ipfs cluster metrics (pin status, replication, etc...)",
+		ChildRequired: true,
+		Children: map[string]cmd.Cmd{
+			"pins": {
+				Blurb:       "Cluster pin summary",
+				Description: "Gets the number of cluster pins by status and replication factor, cross-checked against uploads",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					db := openDatabase(cfg)
+					client := cluster.NewClient(
+						cfg.IPFSCluster.APIConnection.Host+":"+cfg.IPFSCluster.APIConnection.Port,
+						1*time.Minute,
 					)
+					s, err := cluster.NewFarmer(db, client).PinSummary(ctx)
 					if err != nil {
-						fmt.Println("failed to open ipfs api connection", err.Error())
-						os.Exit(1)
+						fatal("failed to get cluster pin summary", err)
 					}
-					uf := upload.NewFarmer(db.DB, ipfs)
-					size, err := uf.AverageUploadSize(*unique)
+					points := []store.Point{
+						metric("cluster.pins", float64(s.Pins)),
+						metric("cluster.missing_from_cluster", float64(s.MissingFromCluster)),
+						metric("cluster.missing_from_uploads", float64(s.MissingFromUploads)),
+					}
+					for status, count := range s.ByStatus {
+						points = append(points, metric("cluster.pins_by_status", float64(count), "status", status))
+					}
+					points = append(points, histogramMetrics("cluster.replication_factors", s.ReplicationFactors)...)
+					record(db, points...)
+					report(cfg, db, "ipfs cluster report",
+						fmt.Sprintf("there are %v total cluster pins", s.Pins),
+						fmt.Sprintf("pinned: %v, pinning: %v, pin_error: %v, unpinned: %v",
+							s.ByStatus[cluster.StatusPinned], s.ByStatus[cluster.StatusPinning],
+							s.ByStatus[cluster.StatusPinError], s.ByStatus[cluster.StatusUnpinned]),
+						fmt.Sprintf("replication factors: %s", s.ReplicationFactors),
+						fmt.Sprintf("there are %v public uploads not pinned by the cluster", s.MissingFromCluster),
+						fmt.Sprintf("there are %v cluster pins without a public upload", s.MissingFromUploads),
+					)
+				},
+			},
+		},
+	},
+	"chart": {
+		Blurb:       "Chart metric history",
+		Description: "Renders the history of a metric over the days flag, filtered by the labels flag, as an svg or png chart",
+		Args:        []string{"metric"},
+		Action: func(cfg config.TemporalConfig, args map[string]string) {
+			metric := args["metric"]
+			out := *chartOut
+			if out == "" {
+				out = metric + ".svg"
+			}
+			points := history(openDatabase(cfg), metric, *days)
+			kind := chart.Kind(*chartKind)
+			switch kind {
+			case chart.Line, chart.Bar, chart.StackedArea:
+			default:
+				fmt.Printf("unsupported chart kind '%s'\n", *chartKind)
+				os.Exit(1)
+			}
+			c := &chart.Chart{
+				Title:  metric,
+				Kind:   kind,
+				Series: chart.FromPoints(points, *chartBy),
+			}
+			file, err := os.Create(out)
+			if err != nil {
+				fatal("failed to create chart file", err)
+			}
+			defer file.Close()
+			if strings.EqualFold(filepath.Ext(out), ".png") {
+				err = c.PNG(file)
+			} else {
+				err = c.SVG(file)
+			}
+			if err != nil {
+				fatal("failed to render chart", err)
+			}
+			fmt.Printf("charted %v points of %s to %s\n", len(points), metric, out)
+		},
+	},
+	"history": {
+		Blurb: "Query metric history",
+		Description: "Prints the history of a metric between the from and to flags, filtered by the labels flag, " +
+			"with one value per step and one column per set of labels, optionally transformed by the fn flag",
+		Args: []string{"metric"},
+		Action: func(cfg config.TemporalConfig, args map[string]string) {
+			metric := args["metric"]
+			to := runTime
+			if *historyTo != "" {
+				t, err := parseTime(*historyTo)
+				if err != nil {
+					fatal("failed to parse to time", err)
+				}
+				to = t
+			}
+			from := to.AddDate(0, 0, -*days)
+			if *historyFrom != "" {
+				t, err := parseTime(*historyFrom)
+				if err != nil {
+					fatal("failed to parse from time", err)
+				}
+				from = t
+			}
+			step, err := query.ParseStep(*historyStep)
+			if err != nil {
+				fatal("failed to parse step", err)
+			}
+			s := openStore(openDatabase(cfg))
+			defer s.Close()
+			points, err := store.History(s, metric, from, to)
+			if err != nil {
+				fatal("failed to query metric history", err)
+			}
+			table, err := query.Align(store.Filter(points, parseLabels()), from, to, step)
+			if err != nil {
+				fatal("failed to align metric history", err)
+			}
+			if table, err = table.Apply(*historyFn); err != nil {
+				fatal("failed to apply functions", err)
+			}
+			if err := table.Write(os.Stdout, *historyFormat); err != nil {
+				fatal("failed to print metric history", err)
+			}
+		},
+	},
+	"diff": {
+		Blurb: "Compare recorded metrics",
+		Description: "Compares every metric recorded as of the from and to flags, each of which may be a run id, " +
+			"or a date or time, and prints the absolute and percentage changes sorted by the magnitude of change",
+		Action: func(cfg config.TemporalConfig, args map[string]string) {
+			if *historyFrom == "" {
+				fmt.Println("the from flag must be set to a run id, date or time")
+				os.Exit(1)
+			}
+			to := *historyTo
+			if to == "" {
+				to = runTime.Format(time.RFC3339Nano)
+			}
+			s := openStore(openDatabase(cfg))
+			defer s.Close()
+			diff := query.Diff(recorded(s, *historyFrom), recorded(s, to))
+			if err := diff.Write(os.Stdout, *historyFormat); err != nil {
+				fatal("failed to print metric changes", err)
+			}
+		},
+	},
+	"forecast": {
+		Blurb:         "Metric forecasts",
+		Description:   "Allows for forecasting metric history using linear, exponential and holt-winters models",
+		ChildRequired: true,
+		Children: map[string]cmd.Cmd{
+			"predict": {
+				Blurb: "Forecast a metric",
+				Description: "Fits each model to the history of a metric, and forecasts it for each of the forecast horizons. " +
+					"metrics with several label values must be narrowed to one with the labels flag.",
+				Args: []string{"metric"},
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					metric := args["metric"]
+					db := openDatabase(cfg)
+					series, err := forecast.FromPoints(history(db, metric, *forecastDays))
 					if err != nil {
-						fmt.Println("failed to get upload size average", err.Error())
-						os.Exit(1)
+						fatal("failed to build the history of "+metric+", narrow it with the labels flag", err)
 					}
-					var uniqueMessage string
-					if *unique {
-						uniqueMessage = "unique"
-					} else {
-						uniqueMessage = "non unique"
+					var horizons []int
+					for _, horizon := range strings.Split(*forecastHorizons, ",") {
+						days, err := strconv.Atoi(strings.TrimSpace(horizon))
+						if err == nil && days < 1 {
+							err = fmt.Errorf("horizon of %v days must be at least 1", days)
+						}
+						if err != nil {
+							fatal("failed to parse forecast horizons", err)
+						}
+						horizons = append(horizons, days)
 					}
-					msg := fmt.Sprintf("the %s average size of uploads is %v gigabytes", uniqueMessage, size)
-					fmt.Println(msg)
-					if *sendEmail {
-						mm, err := mail.NewManager(&cfg, db.DB)
+					sort.Ints(horizons)
+					lines := []string{fmt.Sprintf("forecasts of %s from %v days of history, with 95%% prediction intervals",
+						metric, len(series.Values))}
+					for _, m := range forecast.Models() {
+						if err := m.Fit(series.Values); err != nil {
+							lines = append(lines, fmt.Sprintf("%s: %s", m.Name(), err))
+							continue
+						}
+						predictions, err := m.Forecast(horizons[len(horizons)-1])
 						if err != nil {
-							fmt.Println("failed to initialize mail manager", err.Error())
-							os.Exit(1)
+							fatal("failed to forecast "+metric, err)
 						}
-						if resp, err := mm.SendEmail(
-							"upload size report",
-							msg,
-							"text/html",
-							*recipientName,
-							*emailRecipient,
-						); err != nil {
-							fmt.Println("failed to send email report", err.Error())
-							os.Exit(1)
-						} else {
-							fmt.Printf("resposne from sengrid\n+%v\n", resp)
+						for _, days := range horizons {
+							p := predictions[days-1]
+							lines = append(lines, fmt.Sprintf("%s: %s (%v days): %.2f (%.2f to %.2f)",
+								m.Name(), series.Day(len(series.Values)-1+days).Format("2006-01-02"), days, p.Value, p.Lower, p.Upper))
 						}
 					}
+					report(cfg, db, "forecast report", lines...)
 				},
 			},
+			"backtest": {
+				Blurb: "Backtest forecasts of a metric",
+				Description: "Fits each model to the history of a metric except the held out days, " +
+					"and reports the error of its forecast of the held out days",
+				Args: []string{"metric"},
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					metric := args["metric"]
+					db := openDatabase(cfg)
+					series, err := forecast.FromPoints(history(db, metric, *forecastDays))
+					if err != nil {
+						fatal("failed to build the history of "+metric+", narrow it with the labels flag", err)
+					}
+					lines := []string{fmt.Sprintf("backtest of %s forecasts over the last %v of %v days of history",
+						metric, *forecastHoldout, len(series.Values))}
+					for _, m := range forecast.Models() {
+						a, err := forecast.Backtest(m, series.Values, *forecastHoldout)
+						if err != nil {
+							lines = append(lines, fmt.Sprintf("%s: %s", m.Name(), err))
+							continue
+						}
+						lines = append(lines, fmt.Sprintf("%s: mae %.2f, rmse %.2f, mape %.2f%%, interval coverage %.2f%%",
+							m.Name(), a.MAE, a.RMSE, a.MAPE*100, a.Coverage*100))
+					}
+					report(cfg, db, "forecast backtest report", lines...)
+				},
+			},
+		},
+	},
+	"store": {
+		Blurb:         "Metrics store management",
+		Description:   "Allows for maintaining the metrics store",
+		ChildRequired: true,
+		Children: map[string]cmd.Cmd{
+			"compact": {
+				Blurb: "Compact the metrics store",
+				Description: "Rolls metric history up into hourly, daily, weekly and monthly min, max, avg and last aggregates, " +
+					"and removes history older than the retention of its resolution",
+				Action: func(cfg config.TemporalConfig, args map[string]string) {
+					rules, err := store.ParseRetention(*retention)
+					if err != nil {
+						fatal("failed to parse retention", err)
+					}
+					db := openDatabase(cfg)
+					s := openStore(db)
+					result, err := store.Compact(s, rules, runTime)
+					if err != nil {
+						s.Close()
+						fatal("failed to compact metrics store", err)
+					}
+					if err := s.Close(); err != nil {
+						fatal("failed to close metrics store", err)
+					}
+					report(cfg, db, "metrics store compaction report",
+						fmt.Sprintf("compacted %v metrics", result.Metrics),
+						fmt.Sprintf("recorded %v rollup points", result.Rollups),
+						fmt.Sprintf("removed %v points past retention", result.Deleted),
+					)
+				},
+			},
+		},
+	},
+	"backfill": {
+		Blurb:       "Backfill metric history",
+		Description: "Reconstructs the daily history of metrics from record timestamps, and writes it to the metrics store",
+		Action: func(cfg config.TemporalConfig, args map[string]string) {
+			since, err := time.Parse("2006-01-02", *backfillSince)
+			if err != nil {
+				fatal("failed to parse since date", err)
+			}
+			db := openDatabase(cfg)
+			s := openStore(db)
+			defer s.Close()
+			// backfilled history accounts for deleted rows itself
+			bf := backfill.NewFarmer(asof.Remove(db))
+			var lines []string
+			for _, group := range strings.Split(*backfillMetrics, ",") {
+				points, err := bf.Backfill(strings.TrimSpace(group), since, runTime)
+				if err != nil {
+					fatal("failed to backfill "+group, err)
+				}
+				if err := s.Record(points...); err != nil {
+					fatal("failed to record backfilled "+group, err)
+				}
+				// compacting doesn't revisit periods it has rolled up
+				names := make(map[string]bool)
+				for _, p := range points {
+					names[p.Name] = true
+				}
+				for name := range names {
+					if _, err := store.RollUp(s, name, since, runTime); err != nil {
+						fatal("failed to roll up backfilled "+name, err)
+					}
+				}
+				lines = append(lines, fmt.Sprintf("backfilled %v %s points since %s", len(points), group, *backfillSince))
+			}
+			report(cfg, db, "backfill report", lines...)
+		},
+	},
+	"daily": {
+		Blurb:       "Daily report",
+		Description: "Gets the daily summary of users, uploads and ipfs node activity",
+		Action: func(cfg config.TemporalConfig, args map[string]string) {
+			db := openDatabase(cfg)
+			ipfs := openIPFS(cfg)
+			users, err := user.NewFarmer(db).RegisteredUsers()
+			if err != nil {
+				fatal("failed to get registered users", err)
+			}
+			uploads, err := upload.NewFarmer(db, ipfs).NumberOfUploads()
+			if err != nil {
+				fatal("failed to get number of uploads", err)
+			}
+			m, err := node.NewFarmer(ipfs).Collect(ctx)
+			if err != nil {
+				fatal("failed to collect node metrics", err)
+			}
+			values := m.Values()
+			values["users.registered"] = float64(len(users))
+			values["uploads.total"] = float64(uploads)
+			takeSnapshot(values)
+			record(db, valueMetrics(values)...)
+			lines := []string{
+				fmt.Sprintf("there are %v total registered users", len(users)),
+				fmt.Sprintf("there are %v total uploads", uploads),
+			}
+			lines = append(lines, nodeLines(m)...)
+			if users, ok := dailyDeltas(db, "users.registered", false); ok {
+				uploads, _ := dailyDeltas(db, "uploads.total", false)
+				ingress, _ := dailyDeltas(db, node.TotalInMetric, true)
+				egress, _ := dailyDeltas(db, node.TotalOutMetric, true)
+				lines = append(lines,
+					fmt.Sprintf("new users since the last report: %v", lastDelta(users)),
+					fmt.Sprintf("new uploads since the last report: %v", lastDelta(uploads)),
+					fmt.Sprintf("ingress since the last report: %v", datasize.ByteSize(lastDelta(ingress)).HR()),
+					fmt.Sprintf("egress since the last report: %v", datasize.ByteSize(lastDelta(egress)).HR()),
+				)
+			}
+			subject := "daily report"
+			if *runway {
+				s := openStore(db)
+				runways, alerts := storageRunways(db, s, nodeCapacity(m))
+				s.Close()
+				lines = append(append(alerts, lines...), runways...)
+				if len(alerts) > 0 {
+					subject = "daily report: storage runway alert"
+				}
+			}
+			var images []mail.InlineImage
+			if *sendEmail && *charts {
+				s := openStore(db)
+				images = renderDailyCharts(s)
+				s.Close()
+			}
+			reportImages(cfg, db, subject, images, lines...)
 		},
 	},
 }
@@ -327,8 +1792,11 @@ func main() {
 
 	// initialize global context
 	ctx, cancel = context.WithCancel(context.Background())
+	runID = store.NewRunID()
+	runTime = time.Now()
 
 	// create app
+	applyAsOf(commands)
 	tfarmer := cmd.New(commands, cmd.Config{
 		Name:     "Temporal Farmer",
 		ExecName: "tfarmer",
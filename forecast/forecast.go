@@ -0,0 +1,155 @@
+// Package forecast fits growth models to daily metric history, and
+// projects them forward with prediction intervals
+package forecast
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/RTradeLtd/tfarmer/store"
+)
+
+// z95 is the standard normal quantile bounding a 95% prediction interval
+const z95 = 1.959964
+
+var (
+	// ErrNotFitted is returned when forecasting with a model which hasn't been fitted
+	ErrNotFitted = errors.New("model has not been fitted")
+	// ErrMixedLabels is returned when building a series from the points
+	// of a metric with more than one set of labels
+	ErrMixedLabels = errors.New("points have more than one set of labels")
+)
+
+// Prediction is the forecast value of a single day
+type Prediction struct {
+	// Value is the point forecast
+	Value float64
+	// Lower and Upper bound the 95% prediction interval
+	Lower float64
+	Upper float64
+}
+
+// Model is a forecasting model of a daily series
+type Model interface {
+	// Name returns the name of the model
+	Name() string
+	// Fit is used to fit the model to a daily series
+	Fit(values []float64) error
+	// Forecast is used to predict each of the given number of
+	// days following the series the model was fitted to
+	Forecast(days int) ([]Prediction, error)
+}
+
+// Models returns a new instance of each model, with weekly seasonality
+func Models() []Model {
+	return []Model{NewLinear(), NewExponential(), NewHoltWinters(7)}
+}
+
+// Series is a daily series of values
+type Series struct {
+	// Start is the day of the first value
+	Start time.Time
+	// Values holds a value for each day
+	Values []float64
+}
+
+// Day returns the day of the value at the given index,
+// which may be beyond the end of the series
+func (s Series) Day(i int) time.Time {
+	return s.Start.AddDate(0, 0, i)
+}
+
+// FromPoints is used to build a daily series from metric history, using
+// the last value of each day. days without a value are interpolated from
+// the days either side of them. the points must share one set of labels,
+// as the last values of different series can't be combined.
+func FromPoints(points []store.Point) (Series, error) {
+	for _, p := range points {
+		if !sameLabels(p.Labels, points[0].Labels) {
+			return Series{}, ErrMixedLabels
+		}
+	}
+	daily := store.Daily(points)
+	if len(daily) == 0 {
+		return Series{}, nil
+	}
+	s := Series{Start: daily[0].Time}
+	for i, p := range daily {
+		if i > 0 {
+			prev := daily[i-1]
+			gap := int(p.Time.Sub(prev.Time).Hours() / 24)
+			for d := 1; d < gap; d++ {
+				s.Values = append(s.Values, prev.Value+(p.Value-prev.Value)*float64(d)/float64(gap))
+			}
+		}
+		s.Values = append(s.Values, p.Value)
+	}
+	return s, nil
+}
+
+func sameLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Accuracy is the error of a model's forecast of held out history
+type Accuracy struct {
+	// MAE is the mean absolute error
+	MAE float64
+	// RMSE is the root mean squared error
+	RMSE float64
+	// MAPE is the mean absolute percentage error, ignoring days whose value is 0
+	MAPE float64
+	// Coverage is the share of days whose value fell within the prediction interval
+	Coverage float64
+}
+
+// Backtest is used to fit the model to all but the last holdout values,
+// and measure how well it forecasts the values it didn't see
+func Backtest(m Model, values []float64, holdout int) (*Accuracy, error) {
+	if holdout <= 0 || holdout >= len(values) {
+		return nil, errors.New("holdout must leave history to fit the model to")
+	}
+	train, test := values[:len(values)-holdout], values[len(values)-holdout:]
+	if err := m.Fit(train); err != nil {
+		return nil, err
+	}
+	predictions, err := m.Forecast(holdout)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		a       Accuracy
+		squared float64
+		nonZero int
+		covered int
+	)
+	for i, actual := range test {
+		p := predictions[i]
+		diff := actual - p.Value
+		a.MAE += math.Abs(diff)
+		squared += diff * diff
+		if actual != 0 {
+			a.MAPE += math.Abs(diff / actual)
+			nonZero++
+		}
+		if actual >= p.Lower && actual <= p.Upper {
+			covered++
+		}
+	}
+	a.MAE /= float64(holdout)
+	a.RMSE = math.Sqrt(squared / float64(holdout))
+	if nonZero > 0 {
+		a.MAPE /= float64(nonZero)
+	}
+	a.Coverage = float64(covered) / float64(holdout)
+	return &a, nil
+}
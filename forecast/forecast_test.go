@@ -0,0 +1,209 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/tfarmer/store"
+)
+
+func TestLinear(t *testing.T) {
+	values := make([]float64, 60)
+	for i := range values {
+		// a trend with alternating noise
+		values[i] = 100 + 5*float64(i) + float64(i%2*4-2)
+	}
+	m := NewLinear()
+	if _, err := m.Forecast(1); err != ErrNotFitted {
+		t.Fatal("expected forecasting an unfitted model to fail")
+	}
+	if err := m.Fit(values); err != nil {
+		t.Fatal(err)
+	}
+	predictions, err := m.Forecast(30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(predictions) != 30 {
+		t.Fatalf("expected 30 predictions got %v", len(predictions))
+	}
+	expected := 100 + 5*float64(89)
+	if math.Abs(predictions[29].Value-expected) > 1 {
+		t.Fatalf("expected about %v got %v", expected, predictions[29].Value)
+	}
+	checkIntervals(t, predictions)
+	if err := m.Fit([]float64{1, 2}); err == nil {
+		t.Fatal("expected fitting too short a series to fail")
+	}
+}
+
+func TestExponential(t *testing.T) {
+	values := make([]float64, 60)
+	for i := range values {
+		values[i] = 1000 * math.Pow(1.02, float64(i))
+	}
+	m := NewExponential()
+	if err := m.Fit(values); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(m.DailyGrowth()-0.02) > 1e-9 {
+		t.Fatalf("expected 2%% daily growth got %v", m.DailyGrowth())
+	}
+	predictions, err := m.Forecast(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := 1000 * math.Pow(1.02, 69)
+	if math.Abs(predictions[9].Value-expected) > 1e-6*expected {
+		t.Fatalf("expected %v got %v", expected, predictions[9].Value)
+	}
+	if err := m.Fit([]float64{1, 0, 2}); err == nil {
+		t.Fatal("expected fitting a series with non-positive values to fail")
+	}
+}
+
+func TestHoltWinters(t *testing.T) {
+	weekly := []float64{10, 12, 14, 12, 10, -20, -28}
+	values := make([]float64, 84)
+	for i := range values {
+		values[i] = 500 + 3*float64(i) + weekly[i%7]
+	}
+	m := NewHoltWinters(7)
+	if err := m.Fit(values); err != nil {
+		t.Fatal(err)
+	}
+	predictions, err := m.Forecast(14)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for h, p := range predictions {
+		i := len(values) + h
+		expected := 500 + 3*float64(i) + weekly[i%7]
+		if math.Abs(p.Value-expected) > 5 {
+			t.Fatalf("expected about %v on day %v got %v", expected, h+1, p.Value)
+		}
+	}
+	checkIntervals(t, predictions)
+	if err := NewHoltWinters(7).Fit(values[:10]); err == nil {
+		t.Fatal("expected fitting less than two seasons to fail")
+	}
+}
+
+func TestBacktest(t *testing.T) {
+	values := make([]float64, 100)
+	for i := range values {
+		values[i] = 10 + 2*float64(i)
+	}
+	a, err := Backtest(NewLinear(), values, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.MAE > 1e-9 || a.RMSE > 1e-9 || a.MAPE > 1e-9 || a.Coverage != 1 {
+		t.Fatalf("expected a perfect forecast of a straight line, got %+v", a)
+	}
+	// the held out days double, which no model trained on a line predicts
+	for i := 80; i < 100; i++ {
+		values[i] *= 2
+	}
+	a, err = Backtest(NewLinear(), values, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(a.MAPE-0.5) > 1e-9 || a.Coverage != 0 {
+		t.Fatalf("expected 50%% error and no coverage got %+v", a)
+	}
+	if _, err := Backtest(NewLinear(), values, 100); err == nil {
+		t.Fatal("expected holding out the whole series to fail")
+	}
+}
+
+func TestFromPoints(t *testing.T) {
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	s, err := FromPoints([]store.Point{
+		{Time: day.Add(time.Hour), Value: 10},
+		{Time: day.AddDate(0, 0, 3), Value: 40},
+		{Time: day.AddDate(0, 0, 4), Value: 50},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []float64{10, 20, 30, 40, 50}
+	if !s.Start.Equal(day) || len(s.Values) != len(expected) {
+		t.Fatalf("unexpected series %+v", s)
+	}
+	for i := range expected {
+		if math.Abs(s.Values[i]-expected[i]) > 1e-9 {
+			t.Fatalf("expected %v got %v", expected, s.Values)
+		}
+	}
+	if !s.Day(5).Equal(day.AddDate(0, 0, 5)) {
+		t.Fatal("unexpected day beyond the series")
+	}
+	if _, err := FromPoints([]store.Point{
+		{Time: day, Value: 10, Labels: map[string]string{"tier": "free"}},
+		{Time: day, Value: 20, Labels: map[string]string{"tier": "paid"}},
+	}); err != ErrMixedLabels {
+		t.Fatalf("expected points with several label sets to fail, got %v", err)
+	}
+}
+
+// checkIntervals checks that predictions are bounded by intervals which widen
+func checkIntervals(t *testing.T, predictions []Prediction) {
+	for i, p := range predictions {
+		if p.Lower > p.Value || p.Upper < p.Value {
+			t.Fatalf("prediction %v lies outside its interval %+v", i, p)
+		}
+		if i > 0 && p.Upper-p.Lower < predictions[i-1].Upper-predictions[i-1].Lower {
+			t.Fatalf("interval %v narrower than the one before", i)
+		}
+	}
+}
+
+func TestProjectRunway(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := Series{Start: start}
+	for i := 0; i < 30; i++ {
+		// 10 bytes a day towards a capacity of 1000, with some noise
+		s.Values = append(s.Values, 500+10*float64(i)+float64(i%2*2-1))
+	}
+	r, err := ProjectRunway(NewLinear(), s, 1000, 365)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Crossings) != len(Thresholds) || r.Used != s.Values[29] {
+		t.Fatalf("unexpected runway %+v", r)
+	}
+	// usage is about 790 on the last day, so 80% is a day away, 90% 11 and 100% 21
+	for i, days := range []int{1, 11, 21} {
+		c := r.Crossings[i]
+		if c.Days < days-1 || c.Days > days+1 {
+			t.Fatalf("expected %v%% crossing in about %v days got %v", c.Threshold*100, days, c.Days)
+		}
+		if c.Earliest > c.Days || c.Earliest < 0 {
+			t.Fatalf("expected earliest crossing no later than the forecast, got %+v", c)
+		}
+		if !c.Date.Equal(s.Day(29 + c.Days)) {
+			t.Fatalf("unexpected crossing date %s", c.Date)
+		}
+	}
+	if r.Full().Threshold != 1 {
+		t.Fatal("expected full capacity crossing last")
+	}
+	// a capacity which has been exceeded is crossed now, and one far beyond the horizon never
+	if r, _ := ProjectRunway(NewLinear(), s, 100, 365); r.Full().Days != 0 {
+		t.Fatalf("expected exceeded capacity to be crossed now, got %+v", r.Full())
+	}
+	if r, _ := ProjectRunway(NewLinear(), s, 1e9, 365); r.Full().Days != -1 || r.Full().Earliest != -1 {
+		t.Fatalf("expected capacity beyond the horizon not to be crossed, got %+v", r.Full())
+	}
+	if _, err := ProjectRunway(NewLinear(), s, 0, 365); err == nil {
+		t.Fatal("expected zero capacity to fail")
+	}
+	if m, err := ModelByName("holt-winters"); err != nil || m.Name() != "holt-winters" {
+		t.Fatal("expected to find holt-winters model")
+	}
+	if _, err := ModelByName("magic"); err == nil {
+		t.Fatal("expected unknown model to fail")
+	}
+}
@@ -0,0 +1,129 @@
+package forecast
+
+import (
+	"fmt"
+	"math"
+)
+
+// HoltWinters is additive triple exponential smoothing, modelling the
+// series as a level, a trend, and a seasonal pattern repeating every
+// period days. the smoothing parameters are chosen by grid search to
+// minimize the error of one day ahead forecasts.
+type HoltWinters struct {
+	period int
+	fitted bool
+	// smoothing parameters of the level, trend and season
+	alpha, beta, gamma float64
+	// state after the last day of the series
+	level, trend float64
+	season       []float64
+	n            int
+	// sigma is the standard deviation of one day ahead errors
+	sigma float64
+}
+
+// NewHoltWinters is used to create a holt-winters model whose seasonality
+// repeats every period days, such as 7 for weekly seasonality
+func NewHoltWinters(period int) *HoltWinters {
+	return &HoltWinters{period: period}
+}
+
+// Name returns the name of the model
+func (hw *HoltWinters) Name() string { return "holt-winters" }
+
+// Fit is used to fit the model to a daily series
+func (hw *HoltWinters) Fit(values []float64) error {
+	if hw.period < 2 {
+		return fmt.Errorf("seasonal period must be at least 2 days, not %v", hw.period)
+	}
+	if len(values) < 2*hw.period+1 {
+		return fmt.Errorf("at least %v days of history are required", 2*hw.period+1)
+	}
+	best := math.Inf(1)
+	for a := 0.05; a < 1; a += 0.05 {
+		for b := 0.05; b < 1; b += 0.05 {
+			for g := 0.05; g < 1; g += 0.05 {
+				state := hw.smooth(values, a, b, g)
+				if state.sse < best {
+					best = state.sse
+					hw.alpha, hw.beta, hw.gamma = a, b, g
+					hw.level, hw.trend, hw.season = state.level, state.trend, state.season
+				}
+			}
+		}
+	}
+	hw.n = len(values)
+	hw.sigma = math.Sqrt(best / float64(len(values)-hw.period))
+	hw.fitted = true
+	return nil
+}
+
+// Forecast is used to predict each of the given number of
+// days following the series the model was fitted to
+func (hw *HoltWinters) Forecast(days int) ([]Prediction, error) {
+	if !hw.fitted {
+		return nil, ErrNotFitted
+	}
+	var (
+		predictions = make([]Prediction, 0, days)
+		variance    float64
+	)
+	for h := 1; h <= days; h++ {
+		value := hw.level + float64(h)*hw.trend + hw.season[(hw.n-1+h)%hw.period]
+		// the variance of an h day ahead forecast grows with each
+		// day of smoothing the error passes through
+		if h > 1 {
+			c := hw.alpha * (1 + float64(h-1)*hw.beta)
+			if (h-1)%hw.period == 0 {
+				c += hw.gamma
+			}
+			variance += c * c
+		}
+		width := z95 * hw.sigma * math.Sqrt(1+variance)
+		predictions = append(predictions, Prediction{Value: value, Lower: value - width, Upper: value + width})
+	}
+	return predictions, nil
+}
+
+// smoothed is the state left by smoothing a series
+type smoothed struct {
+	level, trend float64
+	season       []float64
+	sse          float64
+}
+
+// smooth is used to run the smoothing equations over the series. the
+// level and trend are initialized from the first two seasons, and the
+// seasonal pattern from the first season.
+func (hw *HoltWinters) smooth(values []float64, alpha, beta, gamma float64) smoothed {
+	m := hw.period
+	var first, second float64
+	for i := 0; i < m; i++ {
+		first += values[i]
+		second += values[m+i]
+	}
+	first /= float64(m)
+	second /= float64(m)
+	s := smoothed{
+		level:  first,
+		trend:  (second - first) / float64(m),
+		season: make([]float64, m),
+	}
+	for i := 0; i < m; i++ {
+		s.season[i] = values[i] - first
+	}
+	// the first season was used to initialize, so smoothing starts
+	// from a level and trend positioned at the end of that season
+	s.level += s.trend * float64(m-1)
+	for t := m; t < len(values); t++ {
+		i := t % m
+		prediction := s.level + s.trend + s.season[i]
+		err := values[t] - prediction
+		s.sse += err * err
+		level := alpha*(values[t]-s.season[i]) + (1-alpha)*(s.level+s.trend)
+		s.trend = beta*(level-s.level) + (1-beta)*s.trend
+		s.season[i] = gamma*(values[t]-level) + (1-gamma)*s.season[i]
+		s.level = level
+	}
+	return s
+}
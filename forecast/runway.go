@@ -0,0 +1,86 @@
+package forecast
+
+import (
+	"fmt"
+	"time"
+)
+
+// Thresholds are the shares of capacity whose crossing runways project
+var Thresholds = []float64{0.8, 0.9, 1}
+
+// Crossing is the projected crossing of a capacity threshold.
+// days are 0 if the threshold has already been crossed, and
+// -1 if it isn't crossed within the forecast horizon.
+type Crossing struct {
+	// Threshold is the share of capacity
+	Threshold float64
+	// Days is the number of days until the forecast crosses the threshold
+	Days int
+	// Earliest is the number of days until the upper bound
+	// of the forecast's prediction interval crosses the threshold
+	Earliest int
+	// Date is the day the forecast crosses the threshold
+	Date time.Time
+}
+
+// Runway is the projection of a series towards its capacity
+type Runway struct {
+	// Capacity is the value at which the series is full
+	Capacity float64
+	// Used is the last value of the series
+	Used float64
+	// Crossings holds the crossing of each of the thresholds, in order
+	Crossings []Crossing
+}
+
+// Full returns the crossing of full capacity
+func (r *Runway) Full() Crossing {
+	return r.Crossings[len(r.Crossings)-1]
+}
+
+// ProjectRunway is used to fit the model to the series, and project
+// when it will cross each threshold of the capacity within horizon days
+func ProjectRunway(m Model, s Series, capacity float64, horizon int) (*Runway, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("capacity must be positive, not %v", capacity)
+	}
+	if err := m.Fit(s.Values); err != nil {
+		return nil, err
+	}
+	predictions, err := m.Forecast(horizon)
+	if err != nil {
+		return nil, err
+	}
+	last := len(s.Values) - 1
+	r := &Runway{Capacity: capacity, Used: s.Values[last]}
+	for _, threshold := range Thresholds {
+		c := Crossing{Threshold: threshold, Days: -1, Earliest: -1}
+		level := threshold * capacity
+		if r.Used >= level {
+			c.Days, c.Earliest = 0, 0
+		}
+		for h := 1; h <= len(predictions) && c.Days < 0; h++ {
+			if c.Earliest < 0 && predictions[h-1].Upper >= level {
+				c.Earliest = h
+			}
+			if predictions[h-1].Value >= level {
+				c.Days = h
+			}
+		}
+		if c.Days >= 0 {
+			c.Date = s.Day(last + c.Days)
+		}
+		r.Crossings = append(r.Crossings, c)
+	}
+	return r, nil
+}
+
+// ModelByName returns a new instance of the named model
+func ModelByName(name string) (Model, error) {
+	for _, m := range Models() {
+		if m.Name() == name {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown forecast model '%s'", name)
+}
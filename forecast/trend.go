@@ -0,0 +1,141 @@
+package forecast
+
+import (
+	"errors"
+	"math"
+)
+
+// Linear models the series as a straight line fitted by least squares
+type Linear struct {
+	fit *regression
+}
+
+// NewLinear is used to create a linear trend model
+func NewLinear() *Linear { return &Linear{} }
+
+// Name returns the name of the model
+func (l *Linear) Name() string { return "linear" }
+
+// Fit is used to fit the model to a daily series
+func (l *Linear) Fit(values []float64) error {
+	fit, err := regress(values)
+	if err != nil {
+		return err
+	}
+	l.fit = fit
+	return nil
+}
+
+// Forecast is used to predict each of the given number of
+// days following the series the model was fitted to
+func (l *Linear) Forecast(days int) ([]Prediction, error) {
+	if l.fit == nil {
+		return nil, ErrNotFitted
+	}
+	return l.fit.forecast(days, func(v float64) float64 { return v }), nil
+}
+
+// Exponential models the series as growing by a constant rate each day,
+// fitting a straight line to the logarithm of the series. every value
+// of the series must be positive.
+type Exponential struct {
+	fit *regression
+}
+
+// NewExponential is used to create an exponential growth model
+func NewExponential() *Exponential { return &Exponential{} }
+
+// Name returns the name of the model
+func (e *Exponential) Name() string { return "exponential" }
+
+// Fit is used to fit the model to a daily series
+func (e *Exponential) Fit(values []float64) error {
+	logs := make([]float64, len(values))
+	for i, v := range values {
+		if v <= 0 {
+			return errors.New("exponential growth requires positive values")
+		}
+		logs[i] = math.Log(v)
+	}
+	fit, err := regress(logs)
+	if err != nil {
+		return err
+	}
+	e.fit = fit
+	return nil
+}
+
+// Forecast is used to predict each of the given number of
+// days following the series the model was fitted to
+func (e *Exponential) Forecast(days int) ([]Prediction, error) {
+	if e.fit == nil {
+		return nil, ErrNotFitted
+	}
+	return e.fit.forecast(days, math.Exp), nil
+}
+
+// DailyGrowth returns the fitted growth rate per day, such as 0.01 for 1%
+func (e *Exponential) DailyGrowth() float64 {
+	if e.fit == nil {
+		return 0
+	}
+	return math.Exp(e.fit.slope) - 1
+}
+
+// regression is a least squares line fitted to a series, indexed by day
+type regression struct {
+	n         int
+	intercept float64
+	slope     float64
+	// meanX and sxx are the mean and sum of squared deviations of the days
+	meanX float64
+	sxx   float64
+	// se is the standard error of the residuals
+	se float64
+}
+
+func regress(values []float64) (*regression, error) {
+	n := len(values)
+	if n < 3 {
+		return nil, errors.New("at least 3 days of history are required")
+	}
+	var meanX, meanY float64
+	for i, v := range values {
+		meanX += float64(i)
+		meanY += v
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+	var sxx, sxy float64
+	for i, v := range values {
+		dx := float64(i) - meanX
+		sxx += dx * dx
+		sxy += dx * (v - meanY)
+	}
+	r := &regression{n: n, slope: sxy / sxx, meanX: meanX, sxx: sxx}
+	r.intercept = meanY - r.slope*meanX
+	var sse float64
+	for i, v := range values {
+		residual := v - (r.intercept + r.slope*float64(i))
+		sse += residual * residual
+	}
+	r.se = math.Sqrt(sse / float64(n-2))
+	return r, nil
+}
+
+// forecast is used to predict the days following the fitted series, with the
+// classic prediction interval of a regression, transforming each value by fn
+func (r *regression) forecast(days int, fn func(float64) float64) []Prediction {
+	predictions := make([]Prediction, 0, days)
+	for h := 1; h <= days; h++ {
+		x := float64(r.n - 1 + h)
+		value := r.intercept + r.slope*x
+		width := z95 * r.se * math.Sqrt(1+1/float64(r.n)+(x-r.meanX)*(x-r.meanX)/r.sxx)
+		predictions = append(predictions, Prediction{
+			Value: fn(value),
+			Lower: fn(value - width),
+			Upper: fn(value + width),
+		})
+	}
+	return predictions
+}
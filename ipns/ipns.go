@@ -0,0 +1,158 @@
+package ipns
+
+import (
+	"time"
+
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/tfarmer/asof"
+	"github.com/RTradeLtd/tfarmer/stats"
+	"github.com/jinzhu/gorm"
+)
+
+// used to scrape ipns related data
+
+// Farmer is the ipns farmer for Temporal
+type Farmer struct {
+	IM *models.IpnsManager
+}
+
+// NewFarmer instantiates our ipns farmer class
+func NewFarmer(db *gorm.DB) *Farmer {
+	return &Farmer{
+		IM: models.NewIPNSManager(db),
+	}
+}
+
+// DailyCount is the number of records seen on a single day
+type DailyCount struct {
+	Day   time.Time
+	Count int
+}
+
+// Durations summarizes the lifetime and ttl of published records
+type Durations struct {
+	// LifeTimes is a histogram of record lifetimes
+	LifeTimes *stats.Histogram
+	// TTLs is a histogram of record ttls
+	TTLs *stats.Histogram
+	// Invalid is the number of records whose lifetime or ttl can't be parsed
+	Invalid int
+	// Expired is the number of records whose last update is
+	// older than their lifetime, meaning they have likely expired
+	Expired int
+}
+
+// RecordsCreatedPerDay is used to get the number of records
+// created on each day since the given time
+func (f *Farmer) RecordsCreatedPerDay(since time.Time) ([]DailyCount, error) {
+	return f.perDay("created_at", f.IM.DB.Where("created_at >= ?", since))
+}
+
+// RecordsUpdatedPerDay is used to get the number of records updated
+// on each day since the given time. as we only know when a record was
+// last updated, a record republished on several days counts once.
+func (f *Farmer) RecordsUpdatedPerDay(since time.Time) ([]DailyCount, error) {
+	return f.perDay("updated_at", f.IM.DB.Where("sequence > 1 AND updated_at >= ? AND updated_at <= ?",
+		since, asof.Now(f.IM.DB)))
+}
+
+// RecordsByNetwork is used to get the number of records published on each network
+func (f *Farmer) RecordsByNetwork() (map[string]int, error) {
+	rows, err := f.IM.DB.Model(&models.IPNS{}).
+		Select("network_name, count(*)").
+		Group("network_name").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	networks := make(map[string]int)
+	for rows.Next() {
+		var (
+			network string
+			count   int
+		)
+		if err := rows.Scan(&network, &count); err != nil {
+			return nil, err
+		}
+		networks[network] = count
+	}
+	return networks, rows.Err()
+}
+
+// RecordDurations is used to get the distribution of record lifetimes and
+// ttls, as well as the number of records which have likely expired
+func (f *Farmer) RecordDurations() (*Durations, error) {
+	rows, err := f.IM.DB.Model(&models.IPNS{}).Select("life_time, ttl, updated_at").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	d := &Durations{
+		LifeTimes: stats.NewHistogram(
+			[]float64{
+				float64(time.Hour),
+				float64(24 * time.Hour),
+				float64(7 * 24 * time.Hour),
+				float64(30 * 24 * time.Hour),
+			},
+			[]string{"<1h", "1h-24h", "1d-7d", "7d-30d", "30d+"},
+		),
+		TTLs: stats.NewHistogram(
+			[]float64{
+				float64(time.Minute),
+				float64(time.Hour),
+				float64(24 * time.Hour),
+			},
+			[]string{"<1m", "1m-1h", "1h-24h", "24h+"},
+		),
+	}
+	now := asof.Now(f.IM.DB)
+	for rows.Next() {
+		var (
+			lifeTime, ttl string
+			updatedAt     time.Time
+		)
+		if err := rows.Scan(&lifeTime, &ttl, &updatedAt); err != nil {
+			return nil, err
+		}
+		lt, err := time.ParseDuration(lifeTime)
+		if err != nil {
+			d.Invalid++
+			continue
+		}
+		t, err := time.ParseDuration(ttl)
+		if err != nil {
+			d.Invalid++
+			continue
+		}
+		d.LifeTimes.Add(float64(lt))
+		d.TTLs.Add(float64(t))
+		if now.Sub(updatedAt) > lt {
+			d.Expired++
+		}
+	}
+	return d, rows.Err()
+}
+
+// perDay counts the records matched by db, grouped by the day of column
+func (f *Farmer) perDay(column string, db *gorm.DB) ([]DailyCount, error) {
+	rows, err := db.Model(&models.IPNS{}).
+		Select("date_trunc('day', " + column + ") AS day, count(*)").
+		Group("day").
+		Order("day").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := []DailyCount{}
+	for rows.Next() {
+		var c DailyCount
+		if err := rows.Scan(&c.Day, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,85 @@
+package ipns
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/config/v2"
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	testCID      = "QmS4ustL54uo8FzR9455qaxZwuMiUhyvMcX9Ba8nUH4uVv"
+	testIPNSHash = "QmYC4XWNHnh7gMkkXADZX9x9NLCX9f4MY6Wy9GmRpCBVH5"
+)
+
+func TestMigration(t *testing.T) {
+	cfg, err := config.LoadConfig("../testenv/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.IPNS{}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIPNS(t *testing.T) {
+	cfg, err := config.LoadConfig("../testenv/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmer := NewFarmer(db)
+	// create a record with a lifetime that has already passed
+	entry, err := farmer.IM.CreateEntry(
+		testIPNSHash, testCID, "testkey", "public", "testuser1",
+		time.Nanosecond, time.Minute,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer farmer.IM.DB.Unscoped().Delete(entry)
+	created, err := farmer.RecordsCreatedPerDay(time.Now().AddDate(0, 0, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) == 0 || created[len(created)-1].Count == 0 {
+		t.Fatal("failed to find created record")
+	}
+	if _, err := farmer.RecordsUpdatedPerDay(time.Now().AddDate(0, 0, -1)); err != nil {
+		t.Fatal(err)
+	}
+	networks, err := farmer.RecordsByNetwork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if networks["public"] == 0 {
+		t.Fatal("failed to find public network record")
+	}
+	durations, err := farmer.RecordDurations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if durations.Expired == 0 {
+		t.Fatal("failed to find expired record")
+	}
+	if durations.LifeTimes.Total() == 0 || durations.TTLs.Total() == 0 {
+		t.Fatal("failed to count record durations")
+	}
+}
+
+func openDatabaseConnection(cfg *config.TemporalConfig) (*gorm.DB, error) {
+	dbConnURL := fmt.Sprintf("host=127.0.0.1 port=%s user=postgres dbname=temporal password=%s sslmode=disable",
+		cfg.Database.Port, cfg.Database.Password)
+
+	return gorm.Open("postgres", dbConnURL)
+}
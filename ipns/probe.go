@@ -0,0 +1,134 @@
+package ipns
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/rtfs/v2"
+)
+
+// ProbeOptions configures a resolution probe
+type ProbeOptions struct {
+	// Sample is the number of records to resolve
+	Sample int
+	// Concurrency is the number of records resolved at once
+	Concurrency int
+	// Timeout is how long to wait for a single record to resolve
+	Timeout time.Duration
+}
+
+// ProbeResult is the outcome of a resolution probe
+type ProbeResult struct {
+	// Probed is the number of records we attempted to resolve
+	Probed int
+	// Matching is the number of records resolving to their current ipfs hash
+	Matching int
+	// Stale is the number of records resolving to a different ipfs hash
+	Stale int
+	// Unresolvable is the number of records which failed to resolve in time
+	Unresolvable int
+}
+
+// MatchRate returns the share of probed records resolving to their current ipfs hash
+func (r *ProbeResult) MatchRate() float64 { return r.rate(r.Matching) }
+
+// StaleRate returns the share of probed records resolving to a different ipfs hash
+func (r *ProbeResult) StaleRate() float64 { return r.rate(r.Stale) }
+
+// UnresolvableRate returns the share of probed records which failed to resolve
+func (r *ProbeResult) UnresolvableRate() float64 { return r.rate(r.Unresolvable) }
+
+func (r *ProbeResult) rate(n int) float64 {
+	if r.Probed == 0 {
+		return 0
+	}
+	return float64(n) / float64(r.Probed)
+}
+
+// Probe is used to resolve a random sample of public records through the
+// given ipfs node, comparing the result with the ipfs hash we have stored.
+// this gives an outside-in view of how healthy ipns republishing is.
+func (f *Farmer) Probe(ipfs rtfs.Manager, opts ProbeOptions) (*ProbeResult, error) {
+	if opts.Sample <= 0 {
+		return nil, errors.New("sample size must be greater than 0")
+	}
+	entries := []models.IPNS{}
+	if err := f.IM.DB.Where("network_name = ?", "public").
+		Order("random()").
+		Limit(opts.Sample).
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return probe(ipfs, entries, opts), nil
+}
+
+// probe resolves the given entries using a bounded number of workers
+func probe(ipfs rtfs.Manager, entries []models.IPNS, opts ProbeOptions) *ProbeResult {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	var (
+		result = &ProbeResult{Probed: len(entries)}
+		jobs   = make(chan models.IPNS)
+		mux    sync.Mutex
+		wg     sync.WaitGroup
+	)
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range jobs {
+				resolved, err := resolve(ipfs, entry.IPNSHash, opts.Timeout)
+				mux.Lock()
+				switch {
+				case err != nil:
+					result.Unresolvable++
+				case resolved == entry.CurrentIPFSHash:
+					result.Matching++
+				default:
+					result.Stale++
+				}
+				mux.Unlock()
+			}
+		}()
+	}
+	for _, entry := range entries {
+		jobs <- entry
+	}
+	close(jobs)
+	wg.Wait()
+	return result
+}
+
+// resolution is the response of the name/resolve api call
+type resolution struct {
+	Path string
+}
+
+// resolve is used to resolve an ipns hash to the ipfs hash it points to,
+// cancelling the request once the timeout has passed. a timeout of 0 waits
+// for as long as the ipfs client allows.
+func resolve(ipfs rtfs.Manager, hash string, timeout time.Duration) (string, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	resp, err := ipfs.CustomRequest(ctx, ipfs.NodeAddress(), "name/resolve", nil, hash)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", errors.New("timed out resolving " + hash)
+		}
+		return "", err
+	}
+	var r resolution
+	if err := resp.Decode(&r); err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(r.Path, "/ipfs/"), nil
+}
@@ -0,0 +1,86 @@
+package ipns
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/rtfs/v2"
+)
+
+// newFakeResolver starts a fake ipfs http api resolving records from
+// a fixed table after the given delay, which reports on cancelled
+// whenever a resolution is abandoned before it responds
+func newFakeResolver(records map[string]string, delay time.Duration, cancelled chan<- bool) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/id", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ID": "QmFakeNode"}`)
+	})
+	mux.HandleFunc("/api/v0/name/resolve", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			if cancelled != nil {
+				cancelled <- true
+			}
+			return
+		}
+		path, ok := records[r.URL.Query().Get("arg")]
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"Message": "could not resolve name", "Code": 0}`)
+			return
+		}
+		fmt.Fprintf(w, `{"Path": %q}`, path)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestProbe(t *testing.T) {
+	node := newFakeResolver(map[string]string{
+		"matching": "/ipfs/" + testCID,
+		"stale":    "/ipfs/QmOldContent",
+	}, 0, nil)
+	defer node.Close()
+	ipfs, err := rtfs.NewManager(node.URL, "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []models.IPNS{
+		{IPNSHash: "matching", CurrentIPFSHash: testCID},
+		{IPNSHash: "stale", CurrentIPFSHash: testCID},
+		{IPNSHash: "missing", CurrentIPFSHash: testCID},
+		{IPNSHash: "matching", CurrentIPFSHash: testCID},
+	}
+	result := probe(ipfs, entries, ProbeOptions{Concurrency: 2, Timeout: time.Second})
+	if result.Probed != 4 || result.Matching != 2 || result.Stale != 1 || result.Unresolvable != 1 {
+		t.Fatalf("unexpected probe result %+v", result)
+	}
+	if result.MatchRate() != 0.5 || result.StaleRate() != 0.25 || result.UnresolvableRate() != 0.25 {
+		t.Fatal("bad probe rates")
+	}
+}
+
+func TestProbe_Timeout(t *testing.T) {
+	cancelled := make(chan bool, 1)
+	node := newFakeResolver(map[string]string{"slow": "/ipfs/" + testCID}, time.Minute, cancelled)
+	defer node.Close()
+	ipfs, err := rtfs.NewManager(node.URL, "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []models.IPNS{{IPNSHash: "slow", CurrentIPFSHash: testCID}}
+	result := probe(ipfs, entries, ProbeOptions{Timeout: 10 * time.Millisecond})
+	if result.Unresolvable != 1 {
+		t.Fatal("expected slow record to time out")
+	}
+	// the request must be cancelled rather than left running
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the timed out resolution to be cancelled")
+	}
+}
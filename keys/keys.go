@@ -0,0 +1,88 @@
+package keys
+
+import (
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/tfarmer/asof"
+	"github.com/RTradeLtd/tfarmer/stats"
+	"github.com/jinzhu/gorm"
+)
+
+// used to scrape key management related data
+
+// Farmer is the key farmer for Temporal
+type Farmer struct {
+	UM *models.UserManager
+	US *models.UsageManager
+}
+
+// NewFarmer instantiates our key farmer class
+func NewFarmer(db *gorm.DB) *Farmer {
+	return &Farmer{
+		UM: models.NewUserManager(db),
+		US: models.NewUsageManager(db),
+	}
+}
+
+// Summary describes the keys created by accounts
+type Summary struct {
+	// TotalKeys is the number of keys held by all accounts
+	TotalKeys int
+	// KeysPerUser is a histogram of the number of keys held per account
+	KeysPerUser *stats.Histogram
+	// KeysPerTier is the number of keys held by accounts of each tier
+	KeysPerTier map[models.DataUsageTier]int
+	// AtLimit is the number of accounts which can't create any more keys
+	AtLimit int
+	// Inconsistent is the number of accounts whose key counter
+	// disagrees with the number of keys the account holds
+	Inconsistent int
+	// MismatchedArrays is the number of accounts whose number
+	// of key names disagrees with their number of key ids
+	MismatchedArrays int
+}
+
+// KeySummary is used to summarize the keys held by all accounts
+func (f *Farmer) KeySummary() (*Summary, error) {
+	live, args := asof.Live(f.UM.DB, "usages")
+	rows, err := f.UM.DB.Model(&models.User{}).
+		Select("coalesce(array_length(users.ipfs_key_names, 1), 0), "+
+			"coalesce(array_length(users.ipfs_key_ids, 1), 0), "+
+			"coalesce(usages.keys_created, 0), "+
+			"coalesce(usages.keys_allowed, 0), "+
+			"coalesce(usages.tier, '')").
+		Joins("LEFT JOIN usages ON usages.user_name = users.user_name AND "+live, args...).
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	s := &Summary{
+		KeysPerUser: stats.NewHistogram(
+			[]float64{1, 2, 6, 21, 151},
+			[]string{"0", "1", "2-5", "6-20", "21-150", "151+"},
+		),
+		KeysPerTier: make(map[models.DataUsageTier]int),
+	}
+	for rows.Next() {
+		var (
+			names, ids, created, allowed int
+			tier                         string
+		)
+		if err := rows.Scan(&names, &ids, &created, &allowed, &tier); err != nil {
+			return nil, err
+		}
+		s.TotalKeys += names
+		s.KeysPerUser.Add(float64(names))
+		s.KeysPerTier[models.DataUsageTier(tier)] += names
+		if allowed > 0 && created >= allowed {
+			s.AtLimit++
+		}
+		if created != names {
+			s.Inconsistent++
+		}
+		if names != ids {
+			s.MismatchedArrays++
+		}
+	}
+	return s, rows.Err()
+}
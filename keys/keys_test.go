@@ -0,0 +1,77 @@
+package keys
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/RTradeLtd/config/v2"
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/jinzhu/gorm"
+)
+
+func TestMigration(t *testing.T) {
+	cfg, err := config.LoadConfig("../testenv/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Usage{}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	cfg, err := config.LoadConfig("../testenv/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmer := NewFarmer(db)
+	user, err := farmer.UM.NewUserAccount("testkeyuser", "password123", "testkeyuser@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage, err := farmer.US.FindByUserName(user.UserName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer farmer.UM.DB.Unscoped().Delete(user)
+	defer farmer.US.DB.Unscoped().Delete(usage)
+	before, err := farmer.KeySummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// add a key without incrementing the counter
+	if err := farmer.UM.AddIPFSKeyForUser(user.UserName, "testkey", "testkeyid"); err != nil {
+		t.Fatal(err)
+	}
+	after, err := farmer.KeySummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.TotalKeys != before.TotalKeys+1 {
+		t.Fatal("failed to count new key")
+	}
+	if after.KeysPerTier[models.Free] != before.KeysPerTier[models.Free]+1 {
+		t.Fatal("failed to count new key for free tier")
+	}
+	if after.Inconsistent != before.Inconsistent+1 {
+		t.Fatal("failed to flag inconsistent key counter")
+	}
+}
+
+func openDatabaseConnection(cfg *config.TemporalConfig) (*gorm.DB, error) {
+	dbConnURL := fmt.Sprintf("host=127.0.0.1 port=%s user=postgres dbname=temporal password=%s sslmode=disable",
+		cfg.Database.Port, cfg.Database.Password)
+
+	return gorm.Open("postgres", dbConnURL)
+}
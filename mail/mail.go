@@ -1,6 +1,7 @@
 package mail
 
 import (
+	"encoding/base64"
 	"errors"
 	"sync"
 
@@ -48,6 +49,14 @@ func NewManager(tCfg *config.TemporalConfig, db *gorm.DB) (*Manager, error) {
 	}, nil
 }
 
+// InlineImage is an image embedded in an email, which
+// html content displays with <img src="cid:ContentID">
+type InlineImage struct {
+	ContentID   string
+	ContentType string
+	Data        []byte
+}
+
 // BulkSend is used to handle sending a single email, to multiple recipients
 func (mm *Manager) BulkSend(subject, content, contentType string, recipientNames, recipientEmails []string) error {
 	if len(recipientNames) != len(recipientEmails) {
@@ -81,3 +90,23 @@ func (mm *Manager) SendEmail(subject, content, contentType, recipientName, recip
 	}
 	return response, nil
 }
+
+// SendEmailWithImages is used to send an html email with inline images
+func (mm *Manager) SendEmailWithImages(subject, content, recipientName, recipientEmail string, images ...InlineImage) (*rest.Response, error) {
+	mm.cmux.Lock()
+	defer mm.cmux.Unlock()
+	var (
+		from    = mail.NewEmail(mm.EmailName, mm.EmailAddress)
+		to      = mail.NewEmail(recipientName, recipientEmail)
+		message = mail.NewV3MailInit(from, subject, to, mail.NewContent("text/html", content))
+	)
+	for _, image := range images {
+		message.AddAttachment(mail.NewAttachment().
+			SetContent(base64.StdEncoding.EncodeToString(image.Data)).
+			SetType(image.ContentType).
+			SetFilename(image.ContentID).
+			SetDisposition("inline").
+			SetContentID(image.ContentID))
+	}
+	return mm.client.Send(message)
+}
@@ -1,7 +1,10 @@
 package mail_test
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	"image/png"
 	"os"
 	"testing"
 
@@ -55,6 +58,19 @@ func TestMail(t *testing.T) {
 	); err != nil {
 		t.Fatal(err)
 	}
+	var pixel bytes.Buffer
+	if err := png.Encode(&pixel, image.NewNRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mm.SendEmailWithImages(
+		"testEmail",
+		content+`<br><img src="cid:pixel">`,
+		testRecipientName1,
+		testRecipientEmail1,
+		mail.InlineImage{ContentID: "pixel", ContentType: "image/png", Data: pixel.Bytes()},
+	); err != nil {
+		t.Fatal(err)
+	}
 	if err = mm.BulkSend(
 		"testEmail",
 		content,
@@ -0,0 +1,99 @@
+package network
+
+import (
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/tfarmer/stats"
+	"github.com/jinzhu/gorm"
+)
+
+// used to scrape hosted private network related data
+
+// Farmer is the hosted network farmer for Temporal
+type Farmer struct {
+	HM *models.HostedNetworkManager
+}
+
+// NewFarmer instantiates our hosted network farmer class
+func NewFarmer(db *gorm.DB) *Farmer {
+	return &Farmer{
+		HM: models.NewHostedNetworkManager(db),
+	}
+}
+
+// Resources is an amount of allocated resources
+type Resources struct {
+	CPUs     int
+	DiskGB   int
+	MemoryGB int
+}
+
+// Fleet summarizes the hosted private networks
+type Fleet struct {
+	// Total is the number of hosted networks
+	Total int
+	// Online is the number of networks which are currently activated, and aren't disabled
+	Online int
+	// Offline is the number of networks which aren't activated, but aren't disabled
+	Offline int
+	// Disabled is the number of networks which have been disabled
+	Disabled int
+	// PublicGateways is the number of networks exposing a public gateway
+	PublicGateways int
+	// Allocated is the resources allocated to all networks
+	Allocated Resources
+	// AllocatedOnline is the resources allocated to online networks
+	AllocatedOnline Resources
+	// UsersPerNetwork is a histogram of the number of users of each network
+	UsersPerNetwork *stats.Histogram
+	// OwnersPerNetwork is a histogram of the number of owners of each network
+	OwnersPerNetwork *stats.Histogram
+}
+
+// FleetSummary is used to summarize the state and sizing of all hosted networks
+func (f *Farmer) FleetSummary() (*Fleet, error) {
+	networks := []models.HostedNetwork{}
+	if err := f.HM.DB.Model(&models.HostedNetwork{}).Find(&networks).Error; err != nil {
+		return nil, err
+	}
+	return summarize(networks), nil
+}
+
+// summarize computes the fleet summary of the given networks
+func summarize(networks []models.HostedNetwork) *Fleet {
+	fleet := &Fleet{
+		Total:            len(networks),
+		UsersPerNetwork:  newMembersHistogram(),
+		OwnersPerNetwork: newMembersHistogram(),
+	}
+	for _, n := range networks {
+		switch {
+		case n.Disabled:
+			fleet.Disabled++
+		case n.Activated == nil:
+			fleet.Offline++
+		default:
+			fleet.Online++
+			fleet.AllocatedOnline.add(n)
+		}
+		if n.GatewayPublic {
+			fleet.PublicGateways++
+		}
+		fleet.Allocated.add(n)
+		fleet.UsersPerNetwork.Add(float64(len(n.Users)))
+		fleet.OwnersPerNetwork.Add(float64(len(n.Owners)))
+	}
+	return fleet
+}
+
+func (r *Resources) add(n models.HostedNetwork) {
+	r.CPUs += n.ResourcesCPUs
+	r.DiskGB += n.ResourcesDiskGB
+	r.MemoryGB += n.ResourcesMemoryGB
+}
+
+func newMembersHistogram() *stats.Histogram {
+	return stats.NewHistogram(
+		[]float64{1, 2, 6, 21},
+		[]string{"0", "1", "2-5", "6-20", "20+"},
+	)
+}
@@ -0,0 +1,125 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/rtfs/v2"
+	"github.com/c2h5oh/datasize"
+)
+
+func TestSummarize(t *testing.T) {
+	now := time.Now()
+	fleet := summarize([]models.HostedNetwork{
+		{
+			Activated:         &now,
+			GatewayPublic:     true,
+			ResourcesCPUs:     2,
+			ResourcesDiskGB:   100,
+			ResourcesMemoryGB: 4,
+			Owners:            []string{"owner1"},
+			Users:             []string{"user1", "user2"},
+		},
+		{
+			ResourcesCPUs:     1,
+			ResourcesDiskGB:   10,
+			ResourcesMemoryGB: 1,
+			Owners:            []string{"owner1"},
+		},
+		{
+			Disabled:        true,
+			ResourcesDiskGB: 50,
+		},
+		// disabled after being activated, so it isn't online
+		{
+			Activated:       &now,
+			Disabled:        true,
+			ResourcesCPUs:   4,
+			ResourcesDiskGB: 200,
+		},
+	})
+	if fleet.Total != 4 || fleet.Online != 1 || fleet.Offline != 1 || fleet.Disabled != 2 {
+		t.Fatalf("unexpected fleet state %+v", fleet)
+	}
+	if fleet.PublicGateways != 1 {
+		t.Fatal("failed to count public gateway")
+	}
+	if fleet.Allocated != (Resources{CPUs: 7, DiskGB: 360, MemoryGB: 5}) {
+		t.Fatalf("unexpected allocated resources %+v", fleet.Allocated)
+	}
+	if fleet.AllocatedOnline != (Resources{CPUs: 2, DiskGB: 100, MemoryGB: 4}) {
+		t.Fatalf("unexpected online resources %+v", fleet.AllocatedOnline)
+	}
+	if fleet.UsersPerNetwork.String() != "0: 3, 1: 0, 2-5: 1, 6-20: 0, 20+: 0" {
+		t.Fatal("bad users per network", fleet.UsersPerNetwork)
+	}
+	if fleet.OwnersPerNetwork.String() != "0: 2, 1: 2, 2-5: 0, 6-20: 0, 20+: 0" {
+		t.Fatal("bad owners per network", fleet.OwnersPerNetwork)
+	}
+}
+
+// newFakeNode starts a fake ipfs http api, serving the given repo size and peers
+func newFakeNode(repoSize uint64, peers int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/id", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ID": "QmFakeNode"}`)
+	})
+	mux.HandleFunc("/api/v0/repo/stat", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"RepoSize": %v, "StorageMax": 10000000000, "NumObjects": 10}`, repoSize)
+	})
+	mux.HandleFunc("/api/v0/swarm/peers", func(w http.ResponseWriter, r *http.Request) {
+		list := make([]string, 0, peers)
+		for i := 0; i < peers; i++ {
+			list = append(list, fmt.Sprintf(`{"Peer": "QmPeer%v"}`, i))
+		}
+		fmt.Fprintf(w, `{"Peers": [%s]}`, strings.Join(list, ","))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestQueryNode(t *testing.T) {
+	gb := datasize.GB.Bytes()
+	nodes := map[string]*httptest.Server{
+		"full":  newFakeNode(9*gb, 3),
+		"empty": newFakeNode(gb/2, 1),
+		"half":  newFakeNode(5*gb, 2),
+		// without an allocation, so it can't be under-utilized
+		"unallocated": newFakeNode(gb/2, 1),
+	}
+	for _, node := range nodes {
+		defer node.Close()
+	}
+	dial := func(network string) (rtfs.Manager, error) {
+		node, ok := nodes[network]
+		if !ok {
+			return nil, errors.New("unknown network")
+		}
+		return rtfs.NewManager(node.URL, "", time.Minute)
+	}
+	usages := []NodeUsage{}
+	for _, name := range []string{"empty", "full", "half", "missing", "unallocated"} {
+		usage := NodeUsage{Network: name, AllocatedDiskGB: 10}
+		if name == "unallocated" {
+			usage.AllocatedDiskGB = 0
+		}
+		usage.Err = queryNode(dial, &usage, time.Minute)
+		usages = append(usages, usage)
+	}
+	u := tally(usages)
+	if u.Over != 1 || u.Under != 1 || u.Unreachable != 1 {
+		t.Fatalf("unexpected utilization %+v", u)
+	}
+	full := u.Nodes[0]
+	if full.Network != "full" || full.Peers != 3 || full.RepoSize != 9*gb || full.Utilization != 0.9 {
+		t.Fatalf("unexpected usage of full node %+v", full)
+	}
+	if u.Nodes[1].Network != "half" || u.Nodes[1].Utilization != 0.5 {
+		t.Fatal("expected nodes to be sorted by utilization")
+	}
+}
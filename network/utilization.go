@@ -0,0 +1,155 @@
+package network
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/rtfs/v2"
+	"github.com/c2h5oh/datasize"
+)
+
+const (
+	// OverUtilized is the share of allocated disk above which a node is over-utilized
+	OverUtilized = 0.8
+	// UnderUtilized is the share of allocated disk below which a node is under-utilized
+	UnderUtilized = 0.1
+)
+
+// Dialer is used to open an ipfs api connection to a hosted network's node
+type Dialer func(network string) (rtfs.Manager, error)
+
+// NodeUsage is the live resource usage of a single hosted network node
+type NodeUsage struct {
+	Network string
+	// AllocatedDiskGB is the disk allocated to the node
+	AllocatedDiskGB int
+	// RepoSize is the number of bytes stored by the node
+	RepoSize uint64
+	// Peers is the number of peers the node is connected to
+	Peers int
+	// Utilization is the share of allocated disk in use
+	Utilization float64
+	// Err is set if the node couldn't be queried
+	Err error
+}
+
+// Utilization is the live resource usage of all online hosted networks
+type Utilization struct {
+	// Nodes is the usage of each node, most utilized first
+	Nodes []NodeUsage
+	// Over is the number of over-utilized nodes
+	Over int
+	// Under is the number of under-utilized nodes, excluding
+	// nodes without a disk allocation to compare against
+	Under int
+	// Unreachable is the number of nodes which couldn't be queried
+	Unreachable int
+}
+
+// repoStat is the response of the repo/stat api call
+type repoStat struct {
+	RepoSize   uint64
+	StorageMax uint64
+	NumObjects uint64
+}
+
+// swarmPeers is the response of the swarm/peers api call
+type swarmPeers struct {
+	Peers []struct {
+		Peer string
+	}
+}
+
+// LiveUtilization is used to query the node of each online network, which is
+// activated and not disabled, for its actual disk usage and peer count,
+// comparing it against the allocated disk
+func (f *Farmer) LiveUtilization(dial Dialer, timeout time.Duration) (*Utilization, error) {
+	networks := []models.HostedNetwork{}
+	if err := f.HM.DB.Model(&models.HostedNetwork{}).
+		Where("activated IS NOT NULL AND disabled = ?", false).
+		Find(&networks).Error; err != nil {
+		return nil, err
+	}
+	usages := make([]NodeUsage, 0, len(networks))
+	for _, n := range networks {
+		usage := NodeUsage{Network: n.Name, AllocatedDiskGB: n.ResourcesDiskGB}
+		usage.Err = queryNode(dial, &usage, timeout)
+		usages = append(usages, usage)
+	}
+	return tally(usages), nil
+}
+
+// DiskAllocations is used to get the disk allocated to the node of each online
+// network in bytes, which is activated and not disabled, for the networks
+// which have an allocation
+func (f *Farmer) DiskAllocations() (map[string]uint64, error) {
+	networks := []models.HostedNetwork{}
+	if err := f.HM.DB.Model(&models.HostedNetwork{}).
+		Where("activated IS NOT NULL AND disabled = ?", false).
+		Find(&networks).Error; err != nil {
+		return nil, err
+	}
+	allocations := make(map[string]uint64, len(networks))
+	for _, n := range networks {
+		if n.ResourcesDiskGB > 0 {
+			allocations[n.Name] = uint64(n.ResourcesDiskGB) * datasize.GB.Bytes()
+		}
+	}
+	return allocations, nil
+}
+
+// tally classifies each node by its utilization, most utilized first.
+// nodes without a disk allocation have no utilization, so aren't classified.
+func tally(usages []NodeUsage) *Utilization {
+	u := &Utilization{Nodes: usages}
+	for _, usage := range usages {
+		switch {
+		case usage.Err != nil:
+			u.Unreachable++
+		case usage.Utilization >= OverUtilized:
+			u.Over++
+		case usage.AllocatedDiskGB > 0 && usage.Utilization < UnderUtilized:
+			u.Under++
+		}
+	}
+	sort.SliceStable(u.Nodes, func(i, j int) bool {
+		return u.Nodes[i].Utilization > u.Nodes[j].Utilization
+	})
+	return u
+}
+
+// queryNode is used to fill in the live usage of a network's node
+func queryNode(dial Dialer, usage *NodeUsage, timeout time.Duration) error {
+	ipfs, err := dial(usage.Network)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var stat repoStat
+	if err := request(ctx, ipfs, "repo/stat", &stat); err != nil {
+		return err
+	}
+	var peers swarmPeers
+	if err := request(ctx, ipfs, "swarm/peers", &peers); err != nil {
+		return err
+	}
+	usage.RepoSize = stat.RepoSize
+	usage.Peers = len(peers.Peers)
+	if usage.AllocatedDiskGB > 0 {
+		usage.Utilization = float64(stat.RepoSize) / float64(uint64(usage.AllocatedDiskGB)*datasize.GB.Bytes())
+	}
+	return nil
+}
+
+// request is used to make an api call against the node
+// the manager is connected to, decoding the response into out
+func request(ctx context.Context, ipfs rtfs.Manager, command string, out interface{}) error {
+	resp, err := ipfs.CustomRequest(ctx, ipfs.NodeAddress(), command, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Decode(out)
+}
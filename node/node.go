@@ -0,0 +1,107 @@
+package node
+
+import (
+	"context"
+
+	"github.com/RTradeLtd/rtfs/v2"
+)
+
+// used to scrape ipfs node related data
+
+// Farmer is the ipfs node farmer for Temporal
+type Farmer struct {
+	ipfs rtfs.Manager
+}
+
+// NewFarmer instantiates our ipfs node farmer class
+func NewFarmer(ipfs rtfs.Manager) *Farmer {
+	return &Farmer{ipfs: ipfs}
+}
+
+// Metrics is a point in time view of the ipfs node
+type Metrics struct {
+	// RepoSize is the number of bytes stored by the node
+	RepoSize uint64
+	// StorageMax is the maximum number of bytes the node will store
+	StorageMax uint64
+	// NumObjects is the number of objects stored by the node
+	NumObjects uint64
+	// TotalIn is the number of bytes received since the node started
+	TotalIn uint64
+	// TotalOut is the number of bytes sent since the node started
+	TotalOut uint64
+	// RateIn is the current ingress in bytes per second
+	RateIn float64
+	// RateOut is the current egress in bytes per second
+	RateOut float64
+	// Peers is the number of peers the node is connected to
+	Peers int
+}
+
+// Snapshot names of the node metrics
+const (
+	RepoSizeMetric   = "node.repo_size"
+	NumObjectsMetric = "node.objects"
+	TotalInMetric    = "node.bw.total_in"
+	TotalOutMetric   = "node.bw.total_out"
+	PeersMetric      = "node.peers"
+)
+
+// Values returns the metrics as snapshot values
+func (m *Metrics) Values() map[string]float64 {
+	return map[string]float64{
+		RepoSizeMetric:   float64(m.RepoSize),
+		NumObjectsMetric: float64(m.NumObjects),
+		TotalInMetric:    float64(m.TotalIn),
+		TotalOutMetric:   float64(m.TotalOut),
+		PeersMetric:      float64(m.Peers),
+	}
+}
+
+// Collect is used to gather the repo, bandwidth and swarm metrics of the node
+func (f *Farmer) Collect(ctx context.Context) (*Metrics, error) {
+	var stat struct {
+		RepoSize   uint64
+		StorageMax uint64
+		NumObjects uint64
+	}
+	if err := f.request(ctx, "repo/stat", &stat); err != nil {
+		return nil, err
+	}
+	var bw struct {
+		TotalIn  uint64
+		TotalOut uint64
+		RateIn   float64
+		RateOut  float64
+	}
+	if err := f.request(ctx, "stats/bw", &bw); err != nil {
+		return nil, err
+	}
+	var peers struct {
+		Peers []struct {
+			Peer string
+		}
+	}
+	if err := f.request(ctx, "swarm/peers", &peers); err != nil {
+		return nil, err
+	}
+	return &Metrics{
+		RepoSize:   stat.RepoSize,
+		StorageMax: stat.StorageMax,
+		NumObjects: stat.NumObjects,
+		TotalIn:    bw.TotalIn,
+		TotalOut:   bw.TotalOut,
+		RateIn:     bw.RateIn,
+		RateOut:    bw.RateOut,
+		Peers:      len(peers.Peers),
+	}, nil
+}
+
+// request is used to make an api call against the node, decoding the response into out
+func (f *Farmer) request(ctx context.Context, command string, out interface{}) error {
+	resp, err := f.ipfs.CustomRequest(ctx, f.ipfs.NodeAddress(), command, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Decode(out)
+}
@@ -0,0 +1,54 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/rtfs/v2"
+)
+
+func TestCollect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/id", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ID": "QmFakeNode"}`)
+	})
+	mux.HandleFunc("/api/v0/repo/stat", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"RepoSize": 1000, "StorageMax": 5000, "NumObjects": 7}`)
+	})
+	mux.HandleFunc("/api/v0/stats/bw", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"TotalIn": 300, "TotalOut": 400, "RateIn": 1.5, "RateOut": 2.5}`)
+	})
+	mux.HandleFunc("/api/v0/swarm/peers", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Peers": [{"Peer": "QmPeer1"}, {"Peer": "QmPeer2"}]}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	ipfs, err := rtfs.NewManager(server.URL, "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewFarmer(ipfs).Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Metrics{
+		RepoSize:   1000,
+		StorageMax: 5000,
+		NumObjects: 7,
+		TotalIn:    300,
+		TotalOut:   400,
+		RateIn:     1.5,
+		RateOut:    2.5,
+		Peers:      2,
+	}
+	if *m != expected {
+		t.Fatalf("unexpected metrics %+v", m)
+	}
+	if m.Values()[TotalOutMetric] != 400 {
+		t.Fatal("bad snapshot values")
+	}
+}
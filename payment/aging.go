@@ -0,0 +1,107 @@
+package payment
+
+import (
+	"sort"
+	"time"
+
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/tfarmer/asof"
+	"github.com/RTradeLtd/tfarmer/stats"
+)
+
+// Latency is the confirmation latency of payments on a single blockchain
+type Latency struct {
+	Blockchain string
+	// Confirmed is the number of confirmed payments
+	Confirmed int
+	// Median is the median time taken to confirm a payment
+	Median time.Duration
+	// P95 is the 95th percentile time taken to confirm a payment
+	P95 time.Duration
+	// Distribution is a histogram of confirmation latencies
+	Distribution *stats.Histogram
+}
+
+// UnconfirmedAging is used to bucket unconfirmed payments by how long ago
+// they were created. bucket sums are the USD value of the payments.
+func (f *Farmer) UnconfirmedAging() (*stats.Histogram, error) {
+	rows, err := f.PM.DB.Model(&models.Payments{}).
+		Where("confirmed = ?", false).
+		Select("created_at, usd_value").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	aging := stats.NewHistogram(
+		[]float64{
+			float64(time.Hour),
+			float64(24 * time.Hour),
+			float64(7 * 24 * time.Hour),
+		},
+		[]string{"<1h", "1-24h", "1-7d", ">7d"},
+	)
+	now := asof.Now(f.PM.DB)
+	for rows.Next() {
+		var (
+			createdAt time.Time
+			usdValue  float64
+		)
+		if err := rows.Scan(&createdAt, &usdValue); err != nil {
+			return nil, err
+		}
+		aging.AddWeight(float64(now.Sub(createdAt)), usdValue)
+	}
+	return aging, rows.Err()
+}
+
+// ConfirmationLatency is used to get the distribution of time taken to
+// confirm payments on each blockchain. payments are marked confirmed by
+// updating them, so the latency is the time between creation and last update.
+func (f *Farmer) ConfirmationLatency() ([]Latency, error) {
+	rows, err := f.confirmed().
+		Select("blockchain, extract(epoch from (updated_at - created_at))").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	latencies := make(map[string][]float64)
+	for rows.Next() {
+		var (
+			blockchain string
+			seconds    float64
+		)
+		if err := rows.Scan(&blockchain, &seconds); err != nil {
+			return nil, err
+		}
+		latencies[blockchain] = append(latencies[blockchain], seconds*float64(time.Second))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	result := make([]Latency, 0, len(latencies))
+	for blockchain, values := range latencies {
+		l := Latency{
+			Blockchain: blockchain,
+			Confirmed:  len(values),
+			Median:     time.Duration(stats.Quantile(values, 0.5)),
+			P95:        time.Duration(stats.Quantile(values, 0.95)),
+			Distribution: stats.NewHistogram(
+				[]float64{
+					float64(10 * time.Minute),
+					float64(time.Hour),
+					float64(6 * time.Hour),
+					float64(24 * time.Hour),
+				},
+				[]string{"<10m", "10m-1h", "1-6h", "6-24h", ">24h"},
+			),
+		}
+		for _, v := range values {
+			l.Distribution.Add(v)
+		}
+		result = append(result, l)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Blockchain < result[j].Blockchain })
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package payment
+
+import (
+	"math"
+
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/tfarmer/asof"
+	"github.com/RTradeLtd/tfarmer/stats"
+)
+
+// creditEpsilon is the smallest difference between an account's credits and
+// its payment history which we consider a mismatch, ignoring float rounding
+const creditEpsilon = 0.01
+
+// Ledger is the reconciliation of account credits against confirmed payments
+type Ledger struct {
+	// Accounts is the number of accounts reconciled
+	Accounts int
+	// Liability is the total outstanding credit held by all accounts
+	Liability float64
+	// Matching is the number of accounts whose credits match their payment history
+	Matching int
+	// Above is a histogram of accounts holding more credit than they've paid
+	// for, by the size of the difference. this may indicate double-crediting.
+	Above *stats.Histogram
+	// Below is a histogram of accounts holding less credit than they've paid
+	// for, by the size of the difference. this is expected as credits are spent.
+	Below *stats.Histogram
+}
+
+// ReconcileCredits is used to compare the credits held by each
+// account with the sum of its confirmed payments
+func (f *Farmer) ReconcileCredits() (*Ledger, error) {
+	live, args := asof.Live(f.PM.DB, "payments")
+	rows, err := f.PM.DB.Model(&models.User{}).
+		Select("users.credits, coalesce(paid.usd_value, 0)").
+		Joins("LEFT JOIN (SELECT user_name, sum(usd_value) AS usd_value FROM payments "+
+			"WHERE confirmed = ? AND "+live+" GROUP BY user_name) paid "+
+			"ON paid.user_name = users.user_name", append([]interface{}{true}, args...)...).
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	l := &Ledger{
+		Above: newDifferenceHistogram(),
+		Below: newDifferenceHistogram(),
+	}
+	for rows.Next() {
+		var credits, paid float64
+		if err := rows.Scan(&credits, &paid); err != nil {
+			return nil, err
+		}
+		l.Accounts++
+		l.Liability += credits
+		switch diff := credits - paid; {
+		case diff >= creditEpsilon:
+			l.Above.Add(diff)
+		case diff <= -creditEpsilon:
+			l.Below.Add(math.Abs(diff))
+		default:
+			l.Matching++
+		}
+	}
+	return l, rows.Err()
+}
+
+func newDifferenceHistogram() *stats.Histogram {
+	return stats.NewHistogram(
+		[]float64{1, 10, 100, 1000},
+		[]string{"<$1", "$1-10", "$10-100", "$100-1000", "$1000+"},
+	)
+}
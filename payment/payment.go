@@ -0,0 +1,128 @@
+package payment
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/jinzhu/gorm"
+)
+
+// used to scrape payment related data
+
+// Farmer is the payment farmer for Temporal
+type Farmer struct {
+	PM *models.PaymentManager
+}
+
+// NewFarmer instantiates our payment farmer class
+func NewFarmer(db *gorm.DB) *Farmer {
+	return &Farmer{
+		PM: models.NewPaymentManager(db),
+	}
+}
+
+// PeriodRevenue is the confirmed revenue of a single period
+type PeriodRevenue struct {
+	Period time.Time
+	USD    float64
+}
+
+// Share is the revenue attributed to a single payment type or blockchain
+type Share struct {
+	Name  string
+	USD   float64
+	Share float64
+}
+
+// ConfirmedRevenue is used to get the confirmed revenue of each period since
+// the given time. period may be one of "day", "week" or "month".
+func (f *Farmer) ConfirmedRevenue(period string, since time.Time) ([]PeriodRevenue, error) {
+	switch period {
+	case "day", "week", "month":
+	default:
+		return nil, fmt.Errorf("unsupported period '%s'", period)
+	}
+	rows, err := f.confirmed().
+		Where("created_at >= ?", since).
+		Select("date_trunc('" + period + "', created_at) AS period, sum(usd_value)").
+		Group("period").
+		Order("period").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revenue := []PeriodRevenue{}
+	for rows.Next() {
+		var r PeriodRevenue
+		if err := rows.Scan(&r.Period, &r.USD); err != nil {
+			return nil, err
+		}
+		revenue = append(revenue, r)
+	}
+	return revenue, rows.Err()
+}
+
+// RevenueByType is used to get the share of confirmed
+// revenue paid with each payment type (ETH, RTC, XMR, etc...)
+func (f *Farmer) RevenueByType() ([]Share, error) {
+	return f.shares("type")
+}
+
+// RevenueByBlockchain is used to get the share of
+// confirmed revenue paid on each blockchain
+func (f *Farmer) RevenueByBlockchain() ([]Share, error) {
+	return f.shares("blockchain")
+}
+
+// PayingAccounts is used to get the number of accounts
+// which have made at least one confirmed payment
+func (f *Farmer) PayingAccounts() (int, error) {
+	var count int
+	if err := f.confirmed().Select("count(distinct user_name)").Row().Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// shares groups confirmed revenue by column, largest share first
+func (f *Farmer) shares(column string) ([]Share, error) {
+	rows, err := f.confirmed().
+		Select(column + ", sum(usd_value)").
+		Group(column).
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var (
+		shares = []Share{}
+		total  float64
+	)
+	for rows.Next() {
+		var s Share
+		if err := rows.Scan(&s.Name, &s.USD); err != nil {
+			return nil, err
+		}
+		total += s.USD
+		shares = append(shares, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range shares {
+		if total > 0 {
+			shares[i].Share = shares[i].USD / total
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].USD > shares[j].USD })
+	return shares, nil
+}
+
+// confirmed scopes a query to confirmed payments. the confirmed column
+// is stored as a varchar, so it must be compared through a placeholder.
+func (f *Farmer) confirmed() *gorm.DB {
+	return f.PM.DB.Model(&models.Payments{}).Where("confirmed = ?", true)
+}
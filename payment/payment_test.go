@@ -0,0 +1,156 @@
+package payment
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/config/v2"
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/jinzhu/gorm"
+)
+
+func TestMigration(t *testing.T) {
+	cfg, err := config.LoadConfig("../testenv/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Payments{}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPayment(t *testing.T) {
+	cfg, err := config.LoadConfig("../testenv/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmer := NewFarmer(db)
+	payment1, err := farmer.PM.NewPayment(
+		0, "0xdeposit", "0xtesttxhash1", 10, 0.05, "ethereum", "ETH", "testpaymentuser",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer farmer.PM.DB.Unscoped().Delete(payment1)
+	if _, err := farmer.PM.ConfirmPayment(payment1.TxHash); err != nil {
+		t.Fatal(err)
+	}
+	// unconfirmed payments should not count as revenue
+	payment2, err := farmer.PM.NewPayment(
+		1, "0xdeposit", "0xtesttxhash2", 1000, 5, "ethereum", "ETH", "testpaymentuser",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer farmer.PM.DB.Unscoped().Delete(payment2)
+
+	for _, period := range []string{"day", "week", "month"} {
+		revenue, err := farmer.ConfirmedRevenue(period, time.Now().AddDate(0, -1, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(revenue) == 0 || revenue[len(revenue)-1].USD < 10 {
+			t.Fatalf("failed to find confirmed revenue for %s", period)
+		}
+	}
+	if _, err := farmer.ConfirmedRevenue("year", time.Now()); err == nil {
+		t.Fatal("expected error for unsupported period")
+	}
+	types, err := farmer.RevenueByType()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, s := range types {
+		if s.Name == "ETH" && s.USD >= 10 && s.USD < 1000 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("failed to find confirmed ETH revenue")
+	}
+	if _, err := farmer.RevenueByBlockchain(); err != nil {
+		t.Fatal(err)
+	}
+	aging, err := farmer.UnconfirmedAging()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aging.Buckets[0].Count == 0 || aging.Buckets[0].Sum < 1000 {
+		t.Fatal("failed to find recent unconfirmed payment")
+	}
+	latencies, err := farmer.ConfirmationLatency()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found = false
+	for _, l := range latencies {
+		if l.Blockchain == "ethereum" && l.Confirmed > 0 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("failed to find ethereum confirmation latency")
+	}
+	before, err := farmer.ReconcileCredits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an account holding $15 more credit than its $10 of confirmed payments
+	ledgerUser := &models.User{
+		UserName:     fmt.Sprintf("testledgeruser-%v", time.Now().UnixNano()),
+		EmailAddress: fmt.Sprintf("testledgeruser-%v@example.com", time.Now().UnixNano()),
+		Credits:      25,
+	}
+	if err := db.Create(ledgerUser).Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Unscoped().Delete(ledgerUser)
+	payment3, err := farmer.PM.NewPayment(
+		2, "0xdeposit", "0xtesttxhash3", 10, 0.05, "ethereum", "ETH", ledgerUser.UserName,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer farmer.PM.DB.Unscoped().Delete(payment3)
+	if _, err := farmer.PM.ConfirmPayment(payment3.TxHash); err != nil {
+		t.Fatal(err)
+	}
+	ledger, err := farmer.ReconcileCredits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ledger.Accounts != ledger.Matching+ledger.Above.Total()+ledger.Below.Total() {
+		t.Fatal("every account should be reconciled exactly once")
+	}
+	// the difference lands in the $10-100 bucket of accounts above
+	above, previous := ledger.Above.Buckets[2], before.Above.Buckets[2]
+	if above.Count != previous.Count+1 || math.Abs(above.Sum-previous.Sum-15) > creditEpsilon {
+		t.Fatalf("expected a $15 difference above, got %+v from %+v", above, previous)
+	}
+	if ledger.Below.Total() != before.Below.Total() || ledger.Matching != before.Matching {
+		t.Fatal("expected only the seeded account to change the ledger")
+	}
+	if accounts, err := farmer.PayingAccounts(); err != nil {
+		t.Fatal(err)
+	} else if accounts == 0 {
+		t.Fatal("failed to find paying accounts")
+	}
+}
+
+func openDatabaseConnection(cfg *config.TemporalConfig) (*gorm.DB, error) {
+	dbConnURL := fmt.Sprintf("host=127.0.0.1 port=%s user=postgres dbname=temporal password=%s sslmode=disable",
+		cfg.Database.Port, cfg.Database.Password)
+
+	return gorm.Open("postgres", dbConnURL)
+}
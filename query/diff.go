@@ -0,0 +1,147 @@
+package query
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/RTradeLtd/tfarmer/store"
+)
+
+// Change is the change in a series between two sets of points
+type Change struct {
+	Series
+	// From and To are the values of the series in each set, which
+	// are NaN if the series has no value in a set
+	From float64
+	To   float64
+}
+
+// Absolute returns the change in value, treating missing values as 0
+func (c Change) Absolute() float64 {
+	return orZero(c.To) - orZero(c.From)
+}
+
+// Percent returns the change in value as a percentage of the from value,
+// which is infinite if the from value is 0 or missing and the value changed
+func (c Change) Percent() float64 {
+	abs := c.Absolute()
+	if abs == 0 {
+		return 0
+	}
+	if from := orZero(c.From); from != 0 {
+		return abs / math.Abs(from) * 100
+	}
+	return math.Inf(int(math.Copysign(1, abs)))
+}
+
+func orZero(v float64) float64 {
+	if math.IsNaN(v) {
+		return 0
+	}
+	return v
+}
+
+// Changes are the changes between two sets of points
+type Changes []Change
+
+// Diff is used to compare the last value of each series in two sets of
+// points, sorted by the magnitude of their percentage change, then of
+// their absolute change. series which appeared, or grew from 0, sort first.
+func Diff(from, to []store.Point) Changes {
+	var (
+		keys    []string
+		changes = make(map[string]*Change)
+	)
+	collect := func(points []store.Point, value func(c *Change) *float64) {
+		latest := make(map[string]store.Point)
+		for _, p := range points {
+			key := Series{Name: p.Name, Labels: p.Labels}.Key()
+			if last, ok := latest[key]; ok && last.Time.After(p.Time) {
+				continue
+			}
+			latest[key] = p
+		}
+		for key, p := range latest {
+			c, ok := changes[key]
+			if !ok {
+				c = &Change{Series: Series{Name: p.Name, Labels: p.Labels}, From: math.NaN(), To: math.NaN()}
+				changes[key] = c
+				keys = append(keys, key)
+			}
+			*value(c) = p.Value
+		}
+	}
+	collect(from, func(c *Change) *float64 { return &c.From })
+	collect(to, func(c *Change) *float64 { return &c.To })
+	diff := make(Changes, 0, len(keys))
+	for _, key := range keys {
+		diff = append(diff, *changes[key])
+	}
+	sort.Slice(diff, func(i, j int) bool {
+		pi, pj := math.Abs(diff[i].Percent()), math.Abs(diff[j].Percent())
+		if pi != pj {
+			return pi > pj
+		}
+		ai, aj := math.Abs(diff[i].Absolute()), math.Abs(diff[j].Absolute())
+		if ai != aj {
+			return ai > aj
+		}
+		return diff[i].Key() < diff[j].Key()
+	})
+	return diff
+}
+
+// Write is used to write the changes in the given format
+func (c Changes) Write(w io.Writer, format string) error {
+	switch format {
+	case "table":
+		return writeText(w, c.rows("-"))
+	case "json":
+		type jsonChange struct {
+			Name     string            `json:"name"`
+			Labels   map[string]string `json:"labels,omitempty"`
+			From     *float64          `json:"from"`
+			To       *float64          `json:"to"`
+			Absolute float64           `json:"absolute"`
+			Percent  *float64          `json:"percent"`
+		}
+		out := make([]jsonChange, 0, len(c))
+		for _, change := range c {
+			out = append(out, jsonChange{
+				Name:     change.Name,
+				Labels:   change.Labels,
+				From:     finite(change.From),
+				To:       finite(change.To),
+				Absolute: change.Absolute(),
+				Percent:  finite(change.Percent()),
+			})
+		}
+		return writeJSON(w, out)
+	case "csv":
+		return writeCSV(w, c.rows(""))
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// rows returns the header and a row per change, writing missing for missing values
+func (c Changes) rows(missing string) [][]string {
+	rows := [][]string{{"metric", "from", "to", "change", "percent"}}
+	for _, change := range c {
+		percent := missing
+		if p := change.Percent(); !math.IsInf(p, 0) {
+			percent = strconv.FormatFloat(p, 'f', 2, 64) + "%"
+		}
+		rows = append(rows, []string{
+			change.Key(),
+			formatValue(change.From, missing),
+			formatValue(change.To, missing),
+			strconv.FormatFloat(change.Absolute(), 'f', -1, 64),
+			percent,
+		})
+	}
+	return rows
+}
@@ -0,0 +1,124 @@
+package query
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Formats are the formats a table can be written in
+var Formats = []string{"table", "json", "csv"}
+
+// Write is used to write the table in the given format
+func (t *Table) Write(w io.Writer, format string) error {
+	switch format {
+	case "table":
+		return t.WriteText(w)
+	case "json":
+		return t.WriteJSON(w)
+	case "csv":
+		return t.WriteCSV(w)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// WriteText is used to write the table as aligned columns,
+// with a row per step and a column per series
+func (t *Table) WriteText(w io.Writer) error {
+	return writeText(w, t.rows("-"))
+}
+
+// WriteCSV is used to write the table as csv, with a header
+// row, a row per step and a column per series
+func (t *Table) WriteCSV(w io.Writer) error {
+	return writeCSV(w, t.rows(""))
+}
+
+// jsonSeries is the json encoding of a series, where steps without a value are null
+type jsonSeries struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Values []*float64        `json:"values"`
+}
+
+// WriteJSON is used to write the table as json
+func (t *Table) WriteJSON(w io.Writer) error {
+	out := struct {
+		Step   string       `json:"step"`
+		Times  []time.Time  `json:"times"`
+		Series []jsonSeries `json:"series"`
+	}{Step: t.Step.String(), Times: t.Times, Series: make([]jsonSeries, 0, len(t.Series))}
+	for _, s := range t.Series {
+		js := jsonSeries{Name: s.Name, Labels: s.Labels, Values: make([]*float64, len(s.Values))}
+		for i := range s.Values {
+			js.Values[i] = finite(s.Values[i])
+		}
+		out.Series = append(out.Series, js)
+	}
+	return writeJSON(w, out)
+}
+
+// rows returns the header and a row per step, writing missing for steps without a value
+func (t *Table) rows(missing string) [][]string {
+	header := []string{"time"}
+	for _, s := range t.Series {
+		header = append(header, s.Key())
+	}
+	rows := [][]string{header}
+	for i, at := range t.Times {
+		row := []string{at.Format(time.RFC3339)}
+		for _, s := range t.Series {
+			row = append(row, formatValue(s.Values[i], missing))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// writeText is used to write rows as right aligned columns
+func writeText(w io.Writer, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, row := range rows {
+		for _, cell := range row {
+			fmt.Fprint(tw, cell, "\t")
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// formatValue formats a value, writing missing if it's NaN
+func formatValue(v float64, missing string) string {
+	if math.IsNaN(v) {
+		return missing
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// finite returns a pointer to the value, or nil if it isn't finite
+func finite(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,255 @@
+// Package query aligns metric history into series of evenly spaced steps,
+// applies simple functions to them, and formats them for analysis
+package query
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RTradeLtd/tfarmer/store"
+)
+
+// Series is the aligned history of a metric with one set of labels
+type Series struct {
+	Name   string
+	Labels map[string]string
+	// Values holds a value for each step, which is NaN
+	// for steps without a value
+	Values []float64
+}
+
+// Key returns the name of the series followed by its labels, such as
+// users.registered{tier=free}
+func (s Series) Key() string {
+	if len(s.Labels) == 0 {
+		return s.Name
+	}
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+s.Labels[k])
+	}
+	return s.Name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Table is a set of series aligned to the same steps
+type Table struct {
+	// Step is the duration covered by each step
+	Step time.Duration
+	// Times holds the start of each step
+	Times  []time.Time
+	Series []Series
+}
+
+// Align is used to align metric history into series with one value per
+// step, which is the last value recorded within the step. from is
+// truncated to a multiple of step, and steps continue until to.
+func Align(points []store.Point, from, to time.Time, step time.Duration) (*Table, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("step must be positive, not %v", step)
+	}
+	from = from.UTC().Truncate(step)
+	to = to.UTC()
+	if to.Before(from) {
+		return nil, fmt.Errorf("%s is before %s", to.Format(time.RFC3339), from.Format(time.RFC3339))
+	}
+	t := &Table{Step: step}
+	for at := from; !at.After(to); at = at.Add(step) {
+		t.Times = append(t.Times, at)
+	}
+	var (
+		index = make(map[string]int)
+		// latest holds the time of the value of each step of each series
+		latest [][]time.Time
+	)
+	for _, p := range points {
+		if p.Time.Before(from) || p.Time.After(to) {
+			continue
+		}
+		s := Series{Name: p.Name, Labels: p.Labels}
+		key := s.Key()
+		i, ok := index[key]
+		if !ok {
+			i = len(t.Series)
+			index[key] = i
+			s.Values = make([]float64, len(t.Times))
+			for j := range s.Values {
+				s.Values[j] = math.NaN()
+			}
+			t.Series = append(t.Series, s)
+			latest = append(latest, make([]time.Time, len(t.Times)))
+		}
+		j := int(p.Time.Sub(from) / step)
+		if math.IsNaN(t.Series[i].Values[j]) || !p.Time.Before(latest[i][j]) {
+			t.Series[i].Values[j] = p.Value
+			latest[i][j] = p.Time
+		}
+	}
+	t.sort()
+	return t, nil
+}
+
+func (t *Table) sort() {
+	sort.Slice(t.Series, func(i, j int) bool { return t.Series[i].Key() < t.Series[j].Key() })
+}
+
+// apply is used to create a table whose series are the result of fn
+func (t *Table) apply(fn func(values []float64) []float64) *Table {
+	mapped := &Table{Step: t.Step, Times: t.Times, Series: make([]Series, 0, len(t.Series))}
+	for _, s := range t.Series {
+		mapped.Series = append(mapped.Series, Series{Name: s.Name, Labels: s.Labels, Values: fn(s.Values)})
+	}
+	return mapped
+}
+
+// Delta returns the change of each series from the previous step
+func (t *Table) Delta() *Table {
+	return t.apply(delta)
+}
+
+// Rate returns the change of each series from the previous step, per second
+func (t *Table) Rate() *Table {
+	seconds := t.Step.Seconds()
+	return t.apply(func(values []float64) []float64 {
+		rates := delta(values)
+		for i := range rates {
+			rates[i] /= seconds
+		}
+		return rates
+	})
+}
+
+func delta(values []float64) []float64 {
+	deltas := make([]float64, len(values))
+	for i := range values {
+		if i == 0 {
+			deltas[i] = math.NaN()
+			continue
+		}
+		// NaN propagates across steps without a value
+		deltas[i] = values[i] - values[i-1]
+	}
+	return deltas
+}
+
+// MovingAverage returns the average of each series over the last
+// n steps, ignoring steps without a value
+func (t *Table) MovingAverage(n int) *Table {
+	return t.apply(func(values []float64) []float64 {
+		averages := make([]float64, len(values))
+		for i := range values {
+			var (
+				sum   float64
+				count int
+			)
+			for j := i - n + 1; j <= i; j++ {
+				if j >= 0 && !math.IsNaN(values[j]) {
+					sum += values[j]
+					count++
+				}
+			}
+			averages[i] = math.NaN()
+			if count > 0 {
+				averages[i] = sum / float64(count)
+			}
+		}
+		return averages
+	})
+}
+
+// SumBy returns the sum of the series of each metric with the same
+// value of the given label, or of every series of each metric if the
+// label is empty. steps without a value in any series have no sum.
+func (t *Table) SumBy(label string) *Table {
+	var (
+		summed = &Table{Step: t.Step, Times: t.Times}
+		index  = make(map[string]int)
+	)
+	for _, s := range t.Series {
+		group := Series{Name: s.Name}
+		if label != "" {
+			group.Labels = map[string]string{label: s.Labels[label]}
+		}
+		key := group.Key()
+		i, ok := index[key]
+		if !ok {
+			i = len(summed.Series)
+			index[key] = i
+			group.Values = make([]float64, len(t.Times))
+			for j := range group.Values {
+				group.Values[j] = math.NaN()
+			}
+			summed.Series = append(summed.Series, group)
+		}
+		for j, v := range s.Values {
+			if math.IsNaN(v) {
+				continue
+			}
+			if math.IsNaN(summed.Series[i].Values[j]) {
+				summed.Series[i].Values[j] = 0
+			}
+			summed.Series[i].Values[j] += v
+		}
+	}
+	summed.sort()
+	return summed
+}
+
+// Apply is used to apply a comma separated list of functions to the
+// table, in order. the functions are rate, delta, avg:<steps> for a
+// moving average, and sum or sum:<label> to sum series by label.
+func (t *Table) Apply(functions string) (*Table, error) {
+	for _, fn := range strings.Split(functions, ",") {
+		fn = strings.TrimSpace(fn)
+		parts := strings.SplitN(fn, ":", 2)
+		switch parts[0] {
+		case "":
+			continue
+		case "rate":
+			t = t.Rate()
+		case "delta":
+			t = t.Delta()
+		case "avg":
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("function %q requires a number of steps, such as avg:7", fn)
+			}
+			n, err := strconv.Atoi(parts[1])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid number of steps in function %q", fn)
+			}
+			t = t.MovingAverage(n)
+		case "sum":
+			var label string
+			if len(parts) == 2 {
+				label = parts[1]
+			}
+			t = t.SumBy(label)
+		default:
+			return nil, fmt.Errorf("unknown function %q", fn)
+		}
+	}
+	return t, nil
+}
+
+// ParseStep is used to parse a step duration, which may
+// be given in days or weeks, such as 1d or 2w
+func ParseStep(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(s, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(s, suffix))
+			if err != nil {
+				return 0, fmt.Errorf("invalid step %q", s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
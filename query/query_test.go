@@ -0,0 +1,223 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/tfarmer/store"
+)
+
+var day = 24 * time.Hour
+
+func testPoints(start time.Time) []store.Point {
+	return []store.Point{
+		{Time: start.Add(2 * time.Hour), Name: "test.uploads", Labels: map[string]string{"tier": "free"}, Value: 5},
+		// the last value of the day is used
+		{Time: start.Add(9 * time.Hour), Name: "test.uploads", Labels: map[string]string{"tier": "free"}, Value: 10},
+		{Time: start.Add(day + time.Hour), Name: "test.uploads", Labels: map[string]string{"tier": "free"}, Value: 16},
+		{Time: start.Add(3*day + time.Hour), Name: "test.uploads", Labels: map[string]string{"tier": "free"}, Value: 30},
+		{Time: start.Add(time.Hour), Name: "test.uploads", Labels: map[string]string{"tier": "paid"}, Value: 1},
+		{Time: start.Add(day + time.Hour), Name: "test.uploads", Labels: map[string]string{"tier": "paid"}, Value: 3},
+		// outside of the range queried
+		{Time: start.Add(10 * day), Name: "test.uploads", Labels: map[string]string{"tier": "paid"}, Value: 100},
+	}
+}
+
+func TestAlign(t *testing.T) {
+	start := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	table, err := Align(testPoints(start), start.Add(5*time.Hour), start.Add(3*day+time.Hour), day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table.Times) != 4 || !table.Times[0].Equal(start) {
+		t.Fatalf("expected 4 steps from %v got %v", start, table.Times)
+	}
+	if len(table.Series) != 2 {
+		t.Fatalf("expected 2 series got %v", len(table.Series))
+	}
+	free, paid := table.Series[0], table.Series[1]
+	if free.Key() != "test.uploads{tier=free}" || paid.Key() != "test.uploads{tier=paid}" {
+		t.Fatalf("unexpected series %s and %s", free.Key(), paid.Key())
+	}
+	checkValues(t, free.Values, 10, 16, math.NaN(), 30)
+	checkValues(t, paid.Values, 1, 3, math.NaN(), math.NaN())
+
+	if _, err := Align(nil, start, start, 0); err == nil {
+		t.Fatal("expected a step of 0 to fail")
+	}
+	if _, err := Align(nil, start, start.Add(-day), day); err == nil {
+		t.Fatal("expected to before from to fail")
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	start := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	table, err := Align(testPoints(start), start, start.Add(3*day), day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkValues(t, table.Delta().Series[0].Values, math.NaN(), 6, math.NaN(), math.NaN())
+	checkValues(t, table.Rate().Series[1].Values, math.NaN(), 2/day.Seconds(), math.NaN(), math.NaN())
+	checkValues(t, table.MovingAverage(2).Series[0].Values, 10, 13, 16, math.NaN())
+
+	summed := table.SumBy("")
+	if len(summed.Series) != 1 || summed.Series[0].Key() != "test.uploads" {
+		t.Fatalf("expected a single sum got %v series", len(summed.Series))
+	}
+	checkValues(t, summed.Series[0].Values, 11, 19, math.NaN(), math.NaN())
+	if byTier := table.SumBy("tier"); len(byTier.Series) != 2 {
+		t.Fatalf("expected a sum per tier got %v series", len(byTier.Series))
+	}
+
+	applied, err := table.Apply("sum, delta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkValues(t, applied.Series[0].Values, math.NaN(), 8, math.NaN(), math.NaN())
+	for _, fn := range []string{"avg", "avg:0", "median"} {
+		if _, err := table.Apply(fn); err == nil {
+			t.Fatalf("expected %q to fail", fn)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	start := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	table, err := Align(testPoints(start), start, start.Add(3*day), day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := table.Write(&buf, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines got %v", len(lines))
+	}
+	if lines[0] != "time,test.uploads{tier=free},test.uploads{tier=paid}" {
+		t.Fatalf("unexpected header %s", lines[0])
+	}
+	if lines[3] != "2019-03-03T00:00:00Z,," {
+		t.Fatalf("unexpected row %s", lines[3])
+	}
+
+	buf.Reset()
+	if err := table.Write(&buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Series []struct {
+			Name   string
+			Values []*float64
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Series) != 2 || decoded.Series[0].Values[2] != nil || *decoded.Series[0].Values[1] != 16 {
+		t.Fatalf("unexpected json %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := table.Write(&buf, "table"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "test.uploads{tier=paid}") {
+		t.Fatalf("unexpected table %s", buf.String())
+	}
+	if err := table.Write(&buf, "xml"); err == nil {
+		t.Fatal("expected an unknown format to fail")
+	}
+}
+
+func TestParseStep(t *testing.T) {
+	for s, expected := range map[string]time.Duration{
+		"1d":  day,
+		"2w":  14 * day,
+		"6h":  6 * time.Hour,
+		"90m": 90 * time.Minute,
+	} {
+		step, err := ParseStep(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if step != expected {
+			t.Fatalf("expected %s to be %v got %v", s, expected, step)
+		}
+	}
+	if _, err := ParseStep("xd"); err == nil {
+		t.Fatal("expected an invalid step to fail")
+	}
+}
+
+func checkValues(t *testing.T, values []float64, expected ...float64) {
+	t.Helper()
+	if len(values) != len(expected) {
+		t.Fatalf("expected %v values got %v", len(expected), len(values))
+	}
+	for i := range values {
+		if math.IsNaN(expected[i]) || math.IsNaN(values[i]) {
+			if math.IsNaN(expected[i]) != math.IsNaN(values[i]) {
+				t.Fatalf("expected %v got %v", expected, values)
+			}
+			continue
+		}
+		if math.Abs(values[i]-expected[i]) > 1e-9 {
+			t.Fatalf("expected %v got %v", expected, values)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	day := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	from := []store.Point{
+		{Time: day, Name: "test.users", Value: 90},
+		// the last value of a series is compared
+		{Time: day.Add(time.Hour), Name: "test.users", Value: 100},
+		{Time: day, Name: "test.bytes", Value: 1000000},
+		{Time: day, Name: "test.keys", Value: 10},
+		{Time: day, Name: "test.removed", Value: 5},
+	}
+	to := []store.Point{
+		{Time: day, Name: "test.users", Value: 150},
+		{Time: day, Name: "test.bytes", Value: 1100000},
+		{Time: day, Name: "test.keys", Value: 10},
+		{Time: day, Name: "test.added", Value: 1},
+	}
+	diff := Diff(from, to)
+	var order []string
+	for _, c := range diff {
+		order = append(order, c.Key())
+	}
+	expected := "test.added test.removed test.users test.bytes test.keys"
+	if strings.Join(order, " ") != expected {
+		t.Fatalf("expected %s got %s", expected, strings.Join(order, " "))
+	}
+	if diff[2].Absolute() != 50 || diff[2].Percent() != 50 {
+		t.Fatalf("unexpected change %+v", diff[2])
+	}
+	if diff[1].Percent() != -100 || !math.IsNaN(diff[1].To) {
+		t.Fatalf("unexpected change %+v", diff[1])
+	}
+
+	var buf bytes.Buffer
+	if err := diff.Write(&buf, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 6 || lines[1] != "test.added,,1,1," || lines[3] != "test.users,100,150,50,50.00%" {
+		t.Fatalf("unexpected csv %s", buf.String())
+	}
+	buf.Reset()
+	if err := diff.Write(&buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"percent": null`) {
+		t.Fatalf("unexpected json %s", buf.String())
+	}
+}
@@ -0,0 +1,114 @@
+package revenue
+
+import (
+	"time"
+
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/tfarmer/asof"
+	"github.com/RTradeLtd/tfarmer/upload"
+	"github.com/c2h5oh/datasize"
+)
+
+// MonthlyObligation is the prepaid storage we owe during a single month
+type MonthlyObligation struct {
+	Month time.Time
+	USD   float64
+}
+
+// Schedule is the deferred revenue schedule of prepaid storage
+type Schedule struct {
+	// Uploads is the number of prepaid uploads still being stored
+	Uploads int
+	// Total is the prepaid storage obligation remaining across all months
+	Total float64
+	// Months is the obligation of each month, until the last upload expires
+	Months []MonthlyObligation
+}
+
+// obligation is the monthly cost of storing a single upload until it expires
+type obligation struct {
+	monthlyUSD float64
+	until      time.Time
+}
+
+// DeferredRevenue is used to compute how much prepaid storage we still owe,
+// month by month until the last upload is garbage collected. sizes
+// are looked up through the given upload farmer.
+func (f *Farmer) DeferredRevenue(uploads *upload.Farmer) (*Schedule, error) {
+	now := asof.Now(f.US.DB)
+	live, args := asof.Live(f.US.DB, "usages")
+	rows, err := f.US.DB.Model(&models.Upload{}).
+		Select("uploads.hash, uploads.garbage_collect_date, coalesce(usages.tier, '')").
+		Joins("LEFT JOIN usages ON usages.user_name = uploads.user_name AND "+live, args...).
+		Where("uploads.garbage_collect_date > ?", now).
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	obligations := []obligation{}
+	for rows.Next() {
+		var (
+			hash, tier string
+			gcd        time.Time
+		)
+		if err := rows.Scan(&hash, &gcd, &tier); err != nil {
+			return nil, err
+		}
+		if !Billable(models.DataUsageTier(tier)) {
+			continue
+		}
+		size, err := uploads.ObjectSize(hash)
+		if err != nil {
+			return nil, err
+		}
+		obligations = append(obligations, obligation{
+			monthlyUSD: float64(size) / float64(datasize.GB.Bytes()) * models.DataUsageTier(tier).PricePerGB(),
+			until:      gcd,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return schedule(obligations, now), nil
+}
+
+// schedule spreads each obligation across the months from now until it
+// expires, prorating partial months by the share of the month covered
+func schedule(obligations []obligation, now time.Time) *Schedule {
+	s := &Schedule{Uploads: len(obligations), Months: []MonthlyObligation{}}
+	var last time.Time
+	for _, o := range obligations {
+		if o.until.After(last) {
+			last = o.until
+		}
+	}
+	for start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()); start.Before(last); start = start.AddDate(0, 1, 0) {
+		end := start.AddDate(0, 1, 0)
+		m := MonthlyObligation{Month: start}
+		for _, o := range obligations {
+			from, to := later(start, now), earlier(end, o.until)
+			if !to.After(from) {
+				continue
+			}
+			m.USD += o.monthlyUSD * float64(to.Sub(from)) / float64(end.Sub(start))
+		}
+		s.Total += m.USD
+		s.Months = append(s.Months, m)
+	}
+	return s
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earlier(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
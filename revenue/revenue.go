@@ -0,0 +1,124 @@
+package revenue
+
+import (
+	"time"
+
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/tfarmer/asof"
+	"github.com/c2h5oh/datasize"
+	"github.com/jinzhu/gorm"
+)
+
+// used to estimate revenue from usage data
+
+// Farmer is the revenue farmer for Temporal
+type Farmer struct {
+	US *models.UsageManager
+}
+
+// NewFarmer instantiates our revenue farmer class
+func NewFarmer(db *gorm.DB) *Farmer {
+	return &Farmer{
+		US: models.NewUsageManager(db),
+	}
+}
+
+// TierEstimate is the estimated revenue of a single tier
+type TierEstimate struct {
+	Tier models.DataUsageTier
+	// Accounts is the number of accounts in the tier
+	Accounts int
+	// ActiveAccounts is the number of accounts in the tier which used data this month
+	ActiveAccounts int
+	// Bytes is the data used by the tier this month
+	Bytes float64
+	// Revenue is the estimated revenue of the tier this month,
+	// which is always 0 for tiers that aren't billed
+	Revenue float64
+}
+
+// Estimate is the estimated storage revenue of the current month
+type Estimate struct {
+	Tiers []TierEstimate
+	// Revenue is the estimated month to date revenue
+	Revenue float64
+	// Accounts is the number of accounts across all tiers
+	Accounts int
+	// PayingAccounts is the number of accounts in billed tiers which used data this month
+	PayingAccounts int
+	// ARPU is the average revenue per account
+	ARPU float64
+	// ARPPU is the average revenue per paying account
+	ARPPU float64
+	// RunRate is the revenue of the month if usage continues at its current pace
+	RunRate float64
+}
+
+// Billable returns whether accounts in the tier are charged for data.
+// unbilled tiers report a sentinel price per gb, which must never be used.
+func Billable(tier models.DataUsageTier) bool {
+	return tier == models.Paid || tier == models.Partner
+}
+
+// EstimateRevenue is used to estimate this month's storage
+// revenue from the data used by accounts in each tier
+func (f *Farmer) EstimateRevenue() (*Estimate, error) {
+	rows, err := f.US.DB.Model(&models.Usage{}).
+		Select("tier, count(*), " +
+			"count(CASE WHEN current_data_used_bytes > 0 THEN 1 END), " +
+			"coalesce(sum(current_data_used_bytes), 0)").
+		Group("tier").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tiers := []TierEstimate{}
+	for rows.Next() {
+		var (
+			t    TierEstimate
+			tier string
+		)
+		if err := rows.Scan(&tier, &t.Accounts, &t.ActiveAccounts, &t.Bytes); err != nil {
+			return nil, err
+		}
+		t.Tier = models.DataUsageTier(tier)
+		tiers = append(tiers, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return estimate(tiers, asof.Now(f.US.DB)), nil
+}
+
+// estimate prices the usage of each tier, and derives
+// per account averages and the run rate as of now
+func estimate(tiers []TierEstimate, now time.Time) *Estimate {
+	e := &Estimate{Tiers: tiers}
+	for i, t := range e.Tiers {
+		e.Accounts += t.Accounts
+		if !Billable(t.Tier) {
+			continue
+		}
+		e.Tiers[i].Revenue = t.Bytes / float64(datasize.GB.Bytes()) * t.Tier.PricePerGB()
+		e.Revenue += e.Tiers[i].Revenue
+		e.PayingAccounts += t.ActiveAccounts
+	}
+	if e.Accounts > 0 {
+		e.ARPU = e.Revenue / float64(e.Accounts)
+	}
+	if e.PayingAccounts > 0 {
+		e.ARPPU = e.Revenue / float64(e.PayingAccounts)
+	}
+	if elapsed := monthElapsed(now); elapsed > 0 {
+		e.RunRate = e.Revenue / elapsed
+	}
+	return e
+}
+
+// monthElapsed returns the fraction of the month of t which has passed
+func monthElapsed(t time.Time) float64 {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	end := start.AddDate(0, 1, 0)
+	return float64(t.Sub(start)) / float64(end.Sub(start))
+}
@@ -0,0 +1,68 @@
+package revenue
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/c2h5oh/datasize"
+)
+
+func TestEstimate(t *testing.T) {
+	gb := float64(datasize.GB.Bytes())
+	tiers := []TierEstimate{
+		// free usage must never be priced at the sentinel price
+		{Tier: models.Free, Accounts: 96, ActiveAccounts: 50, Bytes: 100 * gb},
+		{Tier: models.Paid, Accounts: 3, ActiveAccounts: 2, Bytes: 100 * gb},
+		{Tier: models.Partner, Accounts: 1, ActiveAccounts: 1, Bytes: 100 * gb},
+	}
+	// half way through a 30 day month
+	now := time.Date(2019, 6, 16, 0, 0, 0, 0, time.UTC)
+	e := estimate(tiers, now)
+	if !closeTo(e.Revenue, 12) {
+		t.Fatalf("expected revenue of 12 got %v", e.Revenue)
+	}
+	if e.Tiers[0].Revenue != 0 {
+		t.Fatal("free tier should have no revenue")
+	}
+	if e.Accounts != 100 || e.PayingAccounts != 3 {
+		t.Fatal("bad account counts")
+	}
+	if !closeTo(e.ARPU, 0.12) || !closeTo(e.ARPPU, 4) {
+		t.Fatalf("bad averages %v %v", e.ARPU, e.ARPPU)
+	}
+	if !closeTo(e.RunRate, 24) {
+		t.Fatalf("expected run rate of 24 got %v", e.RunRate)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	// half way through a 30 day month
+	now := time.Date(2019, 6, 16, 0, 0, 0, 0, time.UTC)
+	s := schedule([]obligation{
+		// expires at the end of next month
+		{monthlyUSD: 10, until: time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC)},
+		// expires at the end of this month
+		{monthlyUSD: 4, until: time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)},
+	}, now)
+	if s.Uploads != 2 || len(s.Months) != 2 {
+		t.Fatalf("unexpected schedule %+v", s)
+	}
+	if !closeTo(s.Months[0].USD, 7) {
+		t.Fatalf("expected half of this month's obligation, got %v", s.Months[0].USD)
+	}
+	if !closeTo(s.Months[1].USD, 10) {
+		t.Fatalf("expected all of next month's obligation, got %v", s.Months[1].USD)
+	}
+	if !closeTo(s.Total, 17) {
+		t.Fatalf("expected total of 17 got %v", s.Total)
+	}
+	if empty := schedule(nil, now); empty.Total != 0 || len(empty.Months) != 0 {
+		t.Fatal("expected empty schedule")
+	}
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
@@ -0,0 +1,109 @@
+// Package snapshot stores point in time metric values in a local
+// file, so that changes can be computed between tfarmer runs
+package snapshot
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+)
+
+const dayFormat = "2006-01-02"
+
+// Snapshot is a set of named values captured at a point in time
+type Snapshot struct {
+	Time   time.Time          `json:"time"`
+	Values map[string]float64 `json:"values"`
+}
+
+// Delta is the change in a value over a single day
+type Delta struct {
+	Day   time.Time
+	Value float64
+}
+
+// Append is used to add a snapshot to the end of the snapshot file,
+// creating the file if it doesn't exist
+func Append(path string, s Snapshot) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(file).Encode(s); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load is used to read all snapshots from the snapshot file, ordered by time.
+// a missing file is treated as having no snapshots.
+func Load(path string) ([]Snapshot, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	snapshots := []Snapshot{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var s Snapshot
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
+
+// DailyDeltas is used to compute the day over day change in the named
+// value, using the last snapshot taken on each day. days without a
+// snapshot are skipped, so a delta may cover more than one day.
+func DailyDeltas(snapshots []Snapshot, name string) []Delta {
+	return dailyDeltas(snapshots, name, false)
+}
+
+// DailyIncreases is used to compute the day over day increase in the named
+// value, treating it as a counter. a counter going backwards means it has
+// been reset, in which case its new value is the increase since the reset.
+func DailyIncreases(snapshots []Snapshot, name string) []Delta {
+	return dailyDeltas(snapshots, name, true)
+}
+
+func dailyDeltas(snapshots []Snapshot, name string, counter bool) []Delta {
+	var (
+		deltas    = []Delta{}
+		days      []time.Time
+		lastOfDay = make(map[string]float64)
+	)
+	for _, s := range snapshots {
+		v, ok := s.Values[name]
+		if !ok {
+			continue
+		}
+		day := s.Time.Format(dayFormat)
+		if _, seen := lastOfDay[day]; !seen {
+			days = append(days, time.Date(s.Time.Year(), s.Time.Month(), s.Time.Day(), 0, 0, 0, 0, s.Time.Location()))
+		}
+		lastOfDay[day] = v
+	}
+	for i := 1; i < len(days); i++ {
+		current := lastOfDay[days[i].Format(dayFormat)]
+		delta := current - lastOfDay[days[i-1].Format(dayFormat)]
+		if counter && delta < 0 {
+			delta = current
+		}
+		deltas = append(deltas, Delta{Day: days[i], Value: delta})
+	}
+	return deltas
+}
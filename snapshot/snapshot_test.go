@@ -0,0 +1,70 @@
+package snapshot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfarmer-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshots.jsonl")
+
+	// a missing file has no snapshots
+	if snapshots, err := Load(path); err != nil {
+		t.Fatal(err)
+	} else if len(snapshots) != 0 {
+		t.Fatal("expected no snapshots")
+	}
+
+	day := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, s := range []Snapshot{
+		{Time: day, Values: map[string]float64{"keys": 10}},
+		{Time: day.Add(time.Hour), Values: map[string]float64{"keys": 12}},
+		{Time: day.AddDate(0, 0, 2), Values: map[string]float64{"keys": 20}},
+		// written out of order, but taken on the second day
+		{Time: day.AddDate(0, 0, 1), Values: map[string]float64{"keys": 15}},
+		{Time: day.AddDate(0, 0, 3), Values: map[string]float64{"other": 1}},
+	} {
+		if err := Append(path, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshots, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 5 {
+		t.Fatal("failed to load all snapshots")
+	}
+	deltas := DailyDeltas(snapshots, "keys")
+	if len(deltas) != 2 {
+		t.Fatalf("expected 2 deltas got %v", len(deltas))
+	}
+	if deltas[0].Value != 3 || deltas[1].Value != 5 {
+		t.Fatalf("unexpected deltas %+v", deltas)
+	}
+}
+
+func TestDailyIncreases(t *testing.T) {
+	day := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	snapshots := []Snapshot{
+		{Time: day, Values: map[string]float64{"bytes": 100}},
+		{Time: day.AddDate(0, 0, 1), Values: map[string]float64{"bytes": 150}},
+		// the counter was reset by a restart
+		{Time: day.AddDate(0, 0, 2), Values: map[string]float64{"bytes": 30}},
+	}
+	increases := DailyIncreases(snapshots, "bytes")
+	if len(increases) != 2 || increases[0].Value != 50 || increases[1].Value != 30 {
+		t.Fatalf("unexpected increases %+v", increases)
+	}
+	if deltas := DailyDeltas(snapshots, "bytes"); deltas[1].Value != -120 {
+		t.Fatal("deltas should not treat values as counters")
+	}
+}
@@ -0,0 +1,150 @@
+// Package stats provides the aggregation helpers shared by the farmers, so
+// that the numbers we report are computed the same way everywhere
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Bucket is a single histogram bucket, counting values in the range [Min, Max).
+// Sum is the sum of the values counted, or of their weights when added with AddWeight.
+type Bucket struct {
+	Label string
+	Min   float64
+	Max   float64
+	Count int
+	Sum   float64
+}
+
+// Histogram counts values into a fixed set of buckets
+type Histogram struct {
+	Buckets []Bucket
+}
+
+// NewHistogram is used to create a histogram whose buckets are bounded
+// by the given edges. labels must contain one entry per bucket, which is
+// len(edges)+1 as the first and last bucket are unbounded below and above.
+func NewHistogram(edges []float64, labels []string) *Histogram {
+	if len(labels) != len(edges)+1 {
+		panic("stats: histogram requires one label per bucket")
+	}
+	buckets := make([]Bucket, 0, len(labels))
+	min := math.Inf(-1)
+	for i, label := range labels {
+		max := math.Inf(1)
+		if i < len(edges) {
+			max = edges[i]
+		}
+		buckets = append(buckets, Bucket{Label: label, Min: min, Max: max})
+		min = max
+	}
+	return &Histogram{Buckets: buckets}
+}
+
+// Add is used to count a value into its bucket
+func (h *Histogram) Add(v float64) {
+	h.AddWeight(v, v)
+}
+
+// AddWeight is used to count a value into its bucket, adding
+// weight to the bucket sum instead of the value itself
+func (h *Histogram) AddWeight(v, weight float64) {
+	for i := range h.Buckets {
+		if v >= h.Buckets[i].Min && v < h.Buckets[i].Max {
+			h.Buckets[i].Count++
+			h.Buckets[i].Sum += weight
+			return
+		}
+	}
+}
+
+// Total returns the number of values counted by the histogram
+func (h *Histogram) Total() int {
+	var total int
+	for _, b := range h.Buckets {
+		total += b.Count
+	}
+	return total
+}
+
+// String returns the histogram as a single "label: count" line
+func (h *Histogram) String() string {
+	parts := make([]string, 0, len(h.Buckets))
+	for _, b := range h.Buckets {
+		parts = append(parts, fmt.Sprintf("%s: %v", b.Label, b.Count))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Sum returns the sum of the given values
+func Sum(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+// Quantile returns the q-th quantile of the given values,
+// interpolating between the closest ranks
+func Quantile(values []float64, q float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
+
+// Gini returns the gini coefficient of the given values, where 0 means
+// the values are perfectly evenly distributed, and values approaching 1
+// mean a single entry holds everything
+func Gini(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	var (
+		total    float64
+		weighted float64
+	)
+	for i, v := range sorted {
+		total += v
+		weighted += float64(i+1) * v
+	}
+	if total == 0 {
+		return 0
+	}
+	n := float64(len(sorted))
+	return (2*weighted)/(n*total) - (n+1)/n
+}
+
+// TopShare returns the share of the total held by the largest fraction
+// of entries, for example a fraction of 0.01 gives the share of the top 1%.
+// at least one entry is always considered part of the top fraction.
+func TopShare(values []float64, fraction float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+	total := Sum(sorted)
+	if total == 0 {
+		return 0
+	}
+	top := int(math.Ceil(float64(len(sorted)) * fraction))
+	if top < 1 {
+		top = 1
+	}
+	if top > len(sorted) {
+		top = len(sorted)
+	}
+	return Sum(sorted[:top]) / total
+}
@@ -0,0 +1,92 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram([]float64{2, 11}, []string{"1", "2-10", "11+"})
+	for _, v := range []float64{1, 1, 2, 10, 11, 500} {
+		h.Add(v)
+	}
+	expected := []int{2, 2, 2}
+	for i, b := range h.Buckets {
+		if b.Count != expected[i] {
+			t.Fatalf("bucket %s: expected %v got %v", b.Label, expected[i], b.Count)
+		}
+	}
+	if h.Total() != 6 {
+		t.Fatal("bad histogram total")
+	}
+	if h.String() != "1: 2, 2-10: 2, 11+: 2" {
+		t.Fatal("bad histogram string", h.String())
+	}
+}
+
+func TestHistogram_AddWeight(t *testing.T) {
+	h := NewHistogram([]float64{10}, []string{"small", "large"})
+	h.AddWeight(1, 100)
+	h.AddWeight(2, 50)
+	h.AddWeight(20, 5)
+	if h.Buckets[0].Count != 2 || h.Buckets[0].Sum != 150 {
+		t.Fatal("bad weighted small bucket")
+	}
+	if h.Buckets[1].Count != 1 || h.Buckets[1].Sum != 5 {
+		t.Fatal("bad weighted large bucket")
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	values := []float64{4, 1, 3, 2}
+	if got := Quantile(values, 0.5); got != 2.5 {
+		t.Fatalf("expected median of 2.5 got %v", got)
+	}
+	if got := Quantile(values, 0); got != 1 {
+		t.Fatalf("expected minimum of 1 got %v", got)
+	}
+	if got := Quantile(values, 1); got != 4 {
+		t.Fatalf("expected maximum of 4 got %v", got)
+	}
+	if got := Quantile(nil, 0.5); got != 0 {
+		t.Fatal("expected 0 for no values")
+	}
+}
+
+func TestGini(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"empty", nil, 0},
+		{"zero", []float64{0, 0}, 0},
+		{"equal", []float64{5, 5, 5, 5}, 0},
+		{"single holder", []float64{0, 0, 0, 10}, 0.75},
+		{"mixed", []float64{1, 2, 3, 4}, 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Gini(tt.values); math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("expected %v got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTopShare(t *testing.T) {
+	values := make([]float64, 0, 100)
+	for i := 0; i < 99; i++ {
+		values = append(values, 1)
+	}
+	values = append(values, 901)
+	if got := TopShare(values, 0.01); got != 0.901 {
+		t.Fatalf("expected top 1%% share of 0.901 got %v", got)
+	}
+	if got := TopShare(values, 0.10); math.Abs(got-0.91) > 1e-9 {
+		t.Fatalf("expected top 10%% share of 0.91 got %v", got)
+	}
+	if got := TopShare([]float64{4}, 0.01); got != 1 {
+		t.Fatalf("expected single entry to hold everything, got %v", got)
+	}
+}
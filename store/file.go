@@ -0,0 +1,442 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// segmentExt is the extension of segment files
+	segmentExt = ".seg"
+	// compactedExt is the extension of segments written by compaction,
+	// which replace every segment numbered before them
+	compactedExt = ".compacted" + segmentExt
+	// maxSegmentSize is the size after which a new segment is started
+	maxSegmentSize = 16 << 20
+	// compactSegments is the number of segments above which
+	// the store is compacted when closed
+	compactSegments = 8
+)
+
+// entry is the encoding of a point within a segment
+type entry struct {
+	Time   time.Time         `json:"t"`
+	Name   string            `json:"n"`
+	Labels map[string]string `json:"l,omitempty"`
+	Value  float64           `json:"v"`
+	RunID  string            `json:"r"`
+}
+
+// File is a metrics store kept in a directory of append-only segment
+// files, for use where tfarmer can't write to the database. each
+// segment holds one json encoded point per line, and is never modified
+// once a newer segment exists. all points are indexed in memory by name
+// when the store is opened. deleted points are removed from the index, and
+// from disk when the store is next compacted, which happens when it's closed.
+//
+// a file store must only be opened by a single process at a time.
+type File struct {
+	dir      string
+	segments []segment
+	active   *os.File
+	size     int64
+	index    map[string][]Point
+	// deleted is set when points have been deleted since the last compaction
+	deleted bool
+}
+
+// segment identifies a segment file
+type segment struct {
+	number    int
+	compacted bool
+}
+
+func (s segment) name() string {
+	if s.compacted {
+		return fmt.Sprintf("%06d%s", s.number, compactedExt)
+	}
+	return fmt.Sprintf("%06d%s", s.number, segmentExt)
+}
+
+// NewFile is used to open the file store kept in the given
+// directory, creating the directory if it doesn't exist
+func NewFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	f := &File{dir: dir, index: make(map[string][]Point)}
+	segments, err := f.listSegments()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range segments {
+		if err := f.load(s); err != nil {
+			return nil, err
+		}
+	}
+	f.segments = segments
+	if len(segments) == 0 {
+		f.segments = []segment{{number: 1}}
+	}
+	for name := range f.index {
+		f.sort(name)
+	}
+	if err := f.openActive(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Record is used to persist the given points
+func (f *File) Record(points ...Point) error {
+	var buf bytes.Buffer
+	for _, point := range points {
+		data, err := json.Marshal(entry(point))
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if f.size > 0 && f.size+int64(buf.Len()) > maxSegmentSize {
+		if err := f.roll(); err != nil {
+			return err
+		}
+	}
+	n, err := f.active.Write(buf.Bytes())
+	f.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if err := f.active.Sync(); err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, point := range points {
+		f.index[point.Name] = append(f.index[point.Name], point)
+		names[point.Name] = true
+	}
+	for name := range names {
+		f.sort(name)
+	}
+	return nil
+}
+
+// Query is used to retrieve all points of the named
+// metric observed within [from, to], ordered by time
+func (f *File) Query(name string, from, to time.Time) ([]Point, error) {
+	points := f.index[name]
+	start := sort.Search(len(points), func(i int) bool { return !points[i].Time.Before(from) })
+	end := sort.Search(len(points), func(i int) bool { return points[i].Time.After(to) })
+	result := []Point{}
+	if start < end {
+		result = append(result, points[start:end]...)
+	}
+	return result, nil
+}
+
+// QueryRun is used to retrieve all points recorded by
+// the given run, ordered by name and then by time
+func (f *File) QueryRun(runID string) ([]Point, error) {
+	names, err := f.Names()
+	if err != nil {
+		return nil, err
+	}
+	points := []Point{}
+	for _, name := range names {
+		for _, point := range f.index[name] {
+			if point.RunID == runID {
+				points = append(points, point)
+			}
+		}
+	}
+	return points, nil
+}
+
+// Last is used to retrieve the last point of each series of the named
+// metric observed at or before the given time, ordered by time
+func (f *File) Last(name string, at time.Time) ([]Point, error) {
+	points := f.index[name]
+	end := sort.Search(len(points), func(i int) bool { return points[i].Time.After(at) })
+	var (
+		last = []Point{}
+		seen = make(map[string]bool)
+	)
+	for i := end - 1; i >= 0; i-- {
+		key := labelKey(points[i].Labels)
+		if !seen[key] {
+			seen[key] = true
+			last = append(last, points[i])
+		}
+	}
+	for i, j := 0, len(last)-1; i < j; i, j = i+1, j-1 {
+		last[i], last[j] = last[j], last[i]
+	}
+	return last, nil
+}
+
+// Names returns the name of every recorded metric, in order
+func (f *File) Names() ([]string, error) {
+	names := make([]string, 0, len(f.index))
+	for name, points := range f.index {
+		if len(points) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Delete is used to remove the points of the named metric observed before the given time
+func (f *File) Delete(name string, before time.Time) (int, error) {
+	points := f.index[name]
+	n := sort.Search(len(points), func(i int) bool { return !points[i].Time.Before(before) })
+	if n == 0 {
+		return 0, nil
+	}
+	if n == len(points) {
+		delete(f.index, name)
+	} else {
+		f.index[name] = append([]Point(nil), points[n:]...)
+	}
+	f.deleted = true
+	return n, nil
+}
+
+// Compact is used to rewrite every point into a single new segment,
+// removing the segments it replaces. the new segment is written in
+// full before any segment is removed, and segments left behind by an
+// interrupted compaction are removed when the store is next opened.
+func (f *File) Compact() error {
+	names := make([]string, 0, len(f.index))
+	for name := range f.index {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	next := segment{number: f.segments[len(f.segments)-1].number + 1, compacted: true}
+	tmp, err := ioutil.TempFile(f.dir, "compact")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for _, name := range names {
+		for _, point := range f.index[name] {
+			data, err := json.Marshal(entry(point))
+			if err != nil {
+				tmp.Close()
+				os.Remove(tmp.Name())
+				return err
+			}
+			w.Write(append(data, '\n'))
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.segmentPath(next)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	// the compacted segment holds everything, so older segments can go
+	if err := f.active.Close(); err != nil {
+		return err
+	}
+	if err := f.removeSegments(f.segments); err != nil {
+		return err
+	}
+	f.segments = []segment{next}
+	f.deleted = false
+	return f.openActive()
+}
+
+// Close is used to close the active segment, compacting the store
+// first if points were deleted or it has accumulated many segments
+func (f *File) Close() error {
+	if f.deleted || len(f.segments) > compactSegments {
+		if err := f.Compact(); err != nil {
+			f.active.Close()
+			return err
+		}
+	}
+	return f.active.Close()
+}
+
+// listSegments returns the live segments of the store in order, removing
+// any segments which were replaced by an interrupted compaction
+func (f *File) listSegments() ([]segment, error) {
+	files, err := ioutil.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []segment
+	for _, file := range files {
+		var s segment
+		switch {
+		case strings.HasSuffix(file.Name(), compactedExt):
+			s.compacted = true
+			if _, err := fmt.Sscanf(strings.TrimSuffix(file.Name(), compactedExt), "%d", &s.number); err != nil {
+				continue
+			}
+		case strings.HasSuffix(file.Name(), segmentExt):
+			if _, err := fmt.Sscanf(strings.TrimSuffix(file.Name(), segmentExt), "%d", &s.number); err != nil {
+				continue
+			}
+		default:
+			continue
+		}
+		segments = append(segments, s)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].number < segments[j].number })
+	for i := len(segments) - 1; i > 0; i-- {
+		if segments[i].compacted {
+			if err := f.removeSegments(segments[:i]); err != nil {
+				return nil, err
+			}
+			return segments[i:], nil
+		}
+	}
+	return segments, nil
+}
+
+// removeSegments is used to delete the given segment files
+func (f *File) removeSegments(segments []segment) error {
+	for _, s := range segments {
+		if err := os.Remove(f.segmentPath(s)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// load is used to read the points of a segment into the index.
+// a partially written final line, left by an interrupted write,
+// is discarded so that later appends start on a new line.
+func (f *File) load(s segment) error {
+	file, err := os.OpenFile(f.segmentPath(s), os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var (
+		r      = bufio.NewReader(file)
+		offset int64
+	)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return file.Truncate(offset)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var e entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("corrupt point in segment %s at offset %v: %s",
+				f.segmentPath(s), offset, err)
+		}
+		f.index[e.Name] = append(f.index[e.Name], Point(e))
+		offset += int64(len(line))
+	}
+}
+
+// openActive is used to open the newest segment for appending
+func (f *File) openActive() error {
+	file, err := os.OpenFile(f.segmentPath(f.segments[len(f.segments)-1]),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.active = file
+	f.size = info.Size()
+	return nil
+}
+
+// roll is used to start a new segment
+func (f *File) roll() error {
+	if err := f.active.Close(); err != nil {
+		return err
+	}
+	f.segments = append(f.segments, segment{number: f.segments[len(f.segments)-1].number + 1})
+	return f.openActive()
+}
+
+// sort is used to keep the points of the named metric ordered by time,
+// keeping points observed at the same time in the order they were recorded.
+// where a point was recorded more than once, only the latest is kept.
+func (f *File) sort(name string) {
+	points := f.index[name]
+	if !sort.SliceIsSorted(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) }) {
+		sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	}
+	f.index[name] = dedupe(points)
+}
+
+// dedupe removes all but the last of the points sharing a name, labels,
+// time and run id, from points which are ordered by time
+func dedupe(points []Point) []Point {
+	kept := points[:0]
+	for start := 0; start < len(points); {
+		end := start + 1
+		for end < len(points) && points[end].Time.Equal(points[start].Time) {
+			end++
+		}
+		for i := start; i < end; i++ {
+			replaced := false
+			for j := i + 1; j < end; j++ {
+				if samePoint(points[i], points[j]) {
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				kept = append(kept, points[i])
+			}
+		}
+		start = end
+	}
+	return kept
+}
+
+// samePoint reports whether two points observed at the same time share an identity
+func samePoint(a, b Point) bool {
+	if a.Name != b.Name || a.RunID != b.RunID || len(a.Labels) != len(b.Labels) {
+		return false
+	}
+	for k, v := range a.Labels {
+		if b.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *File) segmentPath(s segment) string {
+	return filepath.Join(f.dir, s.name())
+}
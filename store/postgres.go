@@ -0,0 +1,191 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// migrations are the schema changes of the metrics tables, applied in order.
+// they are tracked separately from temporal's models, and must never be edited
+// once released; add a new migration instead.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS tfarmer_metrics (
+		id BIGSERIAL PRIMARY KEY,
+		time TIMESTAMP WITH TIME ZONE NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		labels JSONB NOT NULL DEFAULT '{}',
+		value DOUBLE PRECISION NOT NULL,
+		run_id VARCHAR(64) NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS tfarmer_metrics_name_time_idx ON tfarmer_metrics (name, time)`,
+	`CREATE INDEX IF NOT EXISTS tfarmer_metrics_run_id_idx ON tfarmer_metrics (run_id)`,
+	// a point is identified by its name, labels, time and run id
+	`DELETE FROM tfarmer_metrics a USING tfarmer_metrics b
+		WHERE a.id < b.id AND a.name = b.name AND a.labels = b.labels
+		AND a.time = b.time AND a.run_id = b.run_id`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS tfarmer_metrics_point_idx
+		ON tfarmer_metrics (name, labels, time, run_id)`,
+}
+
+// Postgres is a metrics store backed by the tfarmer_metrics table
+type Postgres struct {
+	db *gorm.DB
+}
+
+// NewPostgres is used to open a metrics store using the given
+// database connection, applying any outstanding migrations
+func NewPostgres(db *gorm.DB) (*Postgres, error) {
+	p := &Postgres{db: db}
+	if err := p.migrate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// migrate applies each migration not yet recorded in tfarmer_migrations
+func (p *Postgres) migrate() error {
+	if err := p.db.Exec(`CREATE TABLE IF NOT EXISTS tfarmer_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL
+	)`).Error; err != nil {
+		return err
+	}
+	var current int
+	if err := p.db.Raw("SELECT coalesce(max(version), 0) FROM tfarmer_migrations").
+		Row().Scan(&current); err != nil {
+		return err
+	}
+	for version := current + 1; version <= len(migrations); version++ {
+		tx := p.db.Begin()
+		if err := tx.Exec(migrations[version-1]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Exec("INSERT INTO tfarmer_migrations (version, applied_at) VALUES (?, ?)",
+			version, time.Now()).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Record is used to persist the given points
+func (p *Postgres) Record(points ...Point) error {
+	tx := p.db.Begin()
+	for _, point := range points {
+		labels, err := encodeLabels(point.Labels)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Exec("INSERT INTO tfarmer_metrics (time, name, labels, value, run_id) VALUES (?, ?, ?, ?, ?) "+
+			"ON CONFLICT (name, labels, time, run_id) DO UPDATE SET value = EXCLUDED.value",
+			point.Time, point.Name, labels, point.Value, point.RunID).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// Query is used to retrieve all points of the named
+// metric observed within [from, to], ordered by time
+func (p *Postgres) Query(name string, from, to time.Time) ([]Point, error) {
+	rows, err := p.db.Raw("SELECT time, name, labels, value, run_id FROM tfarmer_metrics "+
+		"WHERE name = ? AND time >= ? AND time <= ? ORDER BY time, id", name, from, to).Rows()
+	if err != nil {
+		return nil, err
+	}
+	return scanPoints(rows)
+}
+
+// QueryRun is used to retrieve all points recorded by
+// the given run, ordered by name and then by time
+func (p *Postgres) QueryRun(runID string) ([]Point, error) {
+	rows, err := p.db.Raw("SELECT time, name, labels, value, run_id FROM tfarmer_metrics "+
+		"WHERE run_id = ? ORDER BY name, time, id", runID).Rows()
+	if err != nil {
+		return nil, err
+	}
+	return scanPoints(rows)
+}
+
+// Last is used to retrieve the last point of each series of the named
+// metric observed at or before the given time, ordered by time
+func (p *Postgres) Last(name string, at time.Time) ([]Point, error) {
+	rows, err := p.db.Raw("SELECT time, name, labels, value, run_id FROM ("+
+		"SELECT DISTINCT ON (labels) time, name, labels, value, run_id, id FROM tfarmer_metrics "+
+		"WHERE name = ? AND time <= ? ORDER BY labels, time DESC, id DESC) last "+
+		"ORDER BY time, id", name, at).Rows()
+	if err != nil {
+		return nil, err
+	}
+	return scanPoints(rows)
+}
+
+// scanPoints is used to read the points selected by a query
+func scanPoints(rows *sql.Rows) ([]Point, error) {
+	defer rows.Close()
+	points := []Point{}
+	for rows.Next() {
+		var (
+			point  Point
+			labels []byte
+		)
+		if err := rows.Scan(&point.Time, &point.Name, &labels, &point.Value, &point.RunID); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(labels, &point.Labels); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// Names returns the name of every recorded metric, in order
+func (p *Postgres) Names() ([]string, error) {
+	rows, err := p.db.Raw("SELECT DISTINCT name FROM tfarmer_metrics ORDER BY name").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// Delete is used to remove the points of the named metric observed before the given time
+func (p *Postgres) Delete(name string, before time.Time) (int, error) {
+	result := p.db.Exec("DELETE FROM tfarmer_metrics WHERE name = ? AND time < ?", name, before)
+	return int(result.RowsAffected), result.Error
+}
+
+// Close is a no-op, as the database connection is owned by the caller
+func (p *Postgres) Close() error { return nil }
+
+// encodeLabels encodes labels as json. map keys are sorted
+// when encoded, so equal labels always encode the same way.
+func encodeLabels(labels map[string]string) (string, error) {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
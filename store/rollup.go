@@ -0,0 +1,447 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RollupRunID is the run id of the points recorded by rollups
+const RollupRunID = "rollup"
+
+// rollupSeparator separates a metric name from its resolution
+// and aggregate in the names of rollup points
+const rollupSeparator = ":"
+
+// Resolution is a period that raw points are rolled up into
+type Resolution string
+
+const (
+	// RawResolution identifies unaggregated points in a retention policy
+	RawResolution Resolution = "raw"
+	// HourlyResolution rollups aggregate the raw points of each hour
+	HourlyResolution Resolution = "hourly"
+	// DailyResolution rollups aggregate the hourly rollups of each day
+	DailyResolution Resolution = "daily"
+	// WeeklyResolution rollups aggregate the daily rollups of each week, starting on monday
+	WeeklyResolution Resolution = "weekly"
+	// MonthlyResolution rollups aggregate the daily rollups of each month
+	MonthlyResolution Resolution = "monthly"
+)
+
+// Resolutions are the resolutions points are rolled up into, in the order
+// they are computed. each is computed from the resolution it's sourced from.
+var Resolutions = []Resolution{HourlyResolution, DailyResolution, WeeklyResolution, MonthlyResolution}
+
+// Aggregates are the aggregates recorded for each period of a rollup.
+// count is the number of raw points aggregated, which weights averages
+// when rolling up into coarser resolutions.
+var Aggregates = []string{"min", "max", "avg", "last", "count"}
+
+// source returns the resolution a rollup is computed from
+func (r Resolution) source() Resolution {
+	switch r {
+	case DailyResolution:
+		return HourlyResolution
+	case WeeklyResolution, MonthlyResolution:
+		return DailyResolution
+	default:
+		return RawResolution
+	}
+}
+
+// Start returns the start of the period containing the given time, in utc
+func (r Resolution) Start(t time.Time) time.Time {
+	t = t.UTC()
+	switch r {
+	case HourlyResolution:
+		return t.Truncate(time.Hour)
+	case DailyResolution:
+		return Day(t)
+	case WeeklyResolution:
+		day := Day(t)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case MonthlyResolution:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return t
+	}
+}
+
+// End returns the end of the period starting at the given time
+func (r Resolution) End(start time.Time) time.Time {
+	switch r {
+	case HourlyResolution:
+		return start.Add(time.Hour)
+	case DailyResolution:
+		return start.AddDate(0, 0, 1)
+	case WeeklyResolution:
+		return start.AddDate(0, 0, 7)
+	case MonthlyResolution:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start
+	}
+}
+
+// RollupName returns the name of the points holding an
+// aggregate of a metric, such as users.registered:daily:avg
+func RollupName(name string, r Resolution, aggregate string) string {
+	return strings.Join([]string{name, string(r), aggregate}, rollupSeparator)
+}
+
+// Retention is how long the points of each resolution are kept, by
+// resolution. resolutions without a retention are kept forever.
+type Retention map[Resolution]time.Duration
+
+// DefaultRetention keeps raw points for 30 days, hourly
+// rollups for 90 days, and coarser rollups forever
+var DefaultRetention = Retention{RawResolution: 30 * 24 * time.Hour, HourlyResolution: 90 * 24 * time.Hour}
+
+// ParseRetention is used to parse a comma separated list of retention rules,
+// such as raw=30d,hourly=90d,daily=forever. durations may be given in days.
+// rules which aren't given are taken from the default retention.
+func ParseRetention(rules string) (Retention, error) {
+	retention := make(Retention)
+	for r, d := range DefaultRetention {
+		retention[r] = d
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		kv := strings.SplitN(rule, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid retention rule %q, expected resolution=duration", rule)
+		}
+		r := Resolution(strings.TrimSpace(kv[0]))
+		switch r {
+		case RawResolution, HourlyResolution, DailyResolution, WeeklyResolution, MonthlyResolution:
+		default:
+			return nil, fmt.Errorf("unknown resolution %q", r)
+		}
+		value := strings.TrimSpace(kv[1])
+		if value == "forever" {
+			delete(retention, r)
+			continue
+		}
+		var (
+			d   time.Duration
+			err error
+		)
+		if strings.HasSuffix(value, "d") {
+			var days int
+			days, err = strconv.Atoi(strings.TrimSuffix(value, "d"))
+			d = time.Duration(days) * 24 * time.Hour
+		} else {
+			d, err = time.ParseDuration(value)
+		}
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid retention %q of %s", value, r)
+		}
+		retention[r] = d
+	}
+	// a resolution must be kept for as long as the periods rolled up from
+	// it, so that every period is rolled up before its source is removed.
+	// the longest of each period, such as a 31 day month, starts at the zero time.
+	for _, r := range Resolutions {
+		d, ok := retention[r.source()]
+		if period := r.End(time.Time{}).Sub(time.Time{}); ok && d < period {
+			return nil, fmt.Errorf("%s points must be kept for at least %v to be rolled up %s", r.source(), period, r)
+		}
+	}
+	return retention, nil
+}
+
+// CompactResult summarizes the changes made by compacting a store
+type CompactResult struct {
+	// Metrics is the number of metrics compacted
+	Metrics int
+	// Rollups is the number of rollup points recorded
+	Rollups int
+	// Deleted is the number of points removed by retention
+	Deleted int
+}
+
+// Compact is used to roll the raw points of every metric up into each
+// resolution, and then remove the points of each resolution which are
+// older than its retention. only periods which ended before now are
+// rolled up. rollups are recomputed from the last period rolled up at each
+// resolution, so compacting is idempotent and picks up points recorded
+// late within that period. points recorded late for earlier periods, such
+// as by a backfill, are rolled up by RollUp. points are only removed in
+// whole periods of the resolutions rolled up from them, so a rollup is
+// never recomputed from part of its period.
+func Compact(s Store, retention Retention, now time.Time) (*CompactResult, error) {
+	names, err := metricNames(s)
+	if err != nil {
+		return nil, err
+	}
+	result := &CompactResult{}
+	for _, name := range names {
+		result.Metrics++
+		for _, r := range Resolutions {
+			last, err := s.Last(RollupName(name, r, "count"), now)
+			if err != nil {
+				return nil, err
+			}
+			var from time.Time
+			for _, p := range last {
+				if p.Time.After(from) {
+					from = p.Time
+				}
+			}
+			recorded, err := recordRollups(s, name, r, from, now)
+			if err != nil {
+				return nil, err
+			}
+			result.Rollups += recorded
+		}
+		for _, r := range append([]Resolution{RawResolution}, Resolutions...) {
+			d, ok := retention[r]
+			if !ok {
+				continue
+			}
+			before := now.Add(-d)
+			// align the cutoff to the start of the periods sourced from r
+			for _, derived := range Resolutions {
+				if derived.source() == r {
+					if start := derived.Start(before); start.Before(before) {
+						before = start
+					}
+				}
+			}
+			var deleted int
+			if r == RawResolution {
+				deleted, err = s.Delete(name, before)
+				result.Deleted += deleted
+			} else {
+				for _, aggregate := range Aggregates {
+					if deleted, err = s.Delete(RollupName(name, r, aggregate), before); err != nil {
+						break
+					}
+					result.Deleted += deleted
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// RollUp is used to recompute the rollups of the named metric at each
+// resolution, from the period containing from to the last period which
+// ended before now, returning the number of rollup points recorded. it's
+// used to roll up points recorded for periods which compacting has
+// already passed, such as by a backfill.
+func RollUp(s Store, name string, from, now time.Time) (int, error) {
+	var total int
+	for _, r := range Resolutions {
+		recorded, err := recordRollups(s, name, r, from, now)
+		if err != nil {
+			return 0, err
+		}
+		total += recorded
+	}
+	return total, nil
+}
+
+// recordRollups is used to compute and record the rollups of the named
+// metric at the given resolution, from the period containing from
+func recordRollups(s Store, name string, r Resolution, from, now time.Time) (int, error) {
+	rollups, err := rollup(s, name, r, from, now)
+	if err != nil || len(rollups) == 0 {
+		return 0, err
+	}
+	if err := s.Record(rollups...); err != nil {
+		return 0, err
+	}
+	return len(rollups), nil
+}
+
+// metricNames returns the name of every raw metric in the store, including
+// metrics whose raw points have all been removed, leaving only rollups
+func metricNames(s Store) ([]string, error) {
+	stored, err := s.Names()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		names []string
+		seen  = make(map[string]bool)
+	)
+	for _, name := range stored {
+		name = strings.SplitN(name, rollupSeparator, 2)[0]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// aggregate is the running aggregate of a period of a series
+type aggregate struct {
+	labels   map[string]string
+	start    time.Time
+	min, max float64
+	sum      float64
+	count    float64
+	last     float64
+	lastTime time.Time
+}
+
+func (a *aggregate) merge(b aggregate) {
+	if a.count == 0 {
+		*a = b
+		return
+	}
+	if b.min < a.min {
+		a.min = b.min
+	}
+	if b.max > a.max {
+		a.max = b.max
+	}
+	a.sum += b.sum
+	a.count += b.count
+	if !b.lastTime.Before(a.lastTime) {
+		a.last, a.lastTime = b.last, b.lastTime
+	}
+}
+
+// rollup is used to compute the rollups of the named metric at the given
+// resolution, for each period from the one containing from which ended
+// before now with source points
+func rollup(s Store, name string, r Resolution, from, now time.Time) ([]Point, error) {
+	var sources []aggregate
+	if !from.IsZero() {
+		from = r.Start(from)
+	}
+	if r.source() == RawResolution {
+		points, err := s.Query(name, from, now)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range points {
+			sources = append(sources, aggregate{
+				labels: p.Labels, start: p.Time,
+				min: p.Value, max: p.Value, sum: p.Value, count: 1,
+				last: p.Value, lastTime: p.Time,
+			})
+		}
+	} else {
+		// rebuild the aggregates of the source periods from their points
+		periods := make(map[string]*aggregate)
+		for _, agg := range Aggregates {
+			points, err := s.Query(RollupName(name, r.source(), agg), from, now)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range points {
+				key := labelKey(p.Labels) + "@" + p.Time.Format(time.RFC3339)
+				a, ok := periods[key]
+				if !ok {
+					a = &aggregate{labels: p.Labels, start: p.Time, lastTime: p.Time}
+					periods[key] = a
+				}
+				switch agg {
+				case "min":
+					a.min = p.Value
+				case "max":
+					a.max = p.Value
+				case "avg":
+					a.sum = p.Value
+				case "last":
+					a.last = p.Value
+				case "count":
+					a.count = p.Value
+				}
+			}
+		}
+		for _, a := range periods {
+			// sum holds the average until the count is known
+			a.sum *= a.count
+			sources = append(sources, *a)
+		}
+	}
+	sort.SliceStable(sources, func(i, j int) bool { return sources[i].start.Before(sources[j].start) })
+
+	var (
+		keys    []string
+		periods = make(map[string]*aggregate)
+	)
+	for _, source := range sources {
+		start := r.Start(source.start)
+		if r.End(start).After(now) {
+			continue
+		}
+		key := labelKey(source.labels) + "@" + start.Format(time.RFC3339)
+		a, ok := periods[key]
+		if !ok {
+			a = &aggregate{}
+			periods[key] = a
+			keys = append(keys, key)
+		}
+		a.merge(source)
+		a.labels, a.start = source.labels, start
+	}
+	points := make([]Point, 0, len(keys)*len(Aggregates))
+	for _, key := range keys {
+		a := periods[key]
+		if a.count == 0 {
+			continue
+		}
+		for i, value := range []float64{a.min, a.max, a.sum / a.count, a.last, a.count} {
+			points = append(points, Point{
+				Time:   a.start,
+				Name:   RollupName(name, r, Aggregates[i]),
+				Labels: a.labels,
+				Value:  value,
+				RunID:  RollupRunID,
+			})
+		}
+	}
+	return points, nil
+}
+
+// labelKey returns an encoding of labels which is equal for equal labels
+func labelKey(labels map[string]string) string {
+	key, _ := encodeLabels(labels)
+	return key
+}
+
+// History is used to retrieve the points of the named metric observed within
+// [from, to], along with its daily last rollups, named as the metric, for the
+// days which have no raw points left. the last value of each day therefore
+// remains available once raw points are removed.
+func History(s Store, name string, from, to time.Time) ([]Point, error) {
+	// raw points after to are only used to tell which days still have them
+	points, err := s.Query(name, from, DailyResolution.End(Day(to)).Add(-time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+	raw := make(map[string]bool)
+	for _, p := range points {
+		raw[labelKey(p.Labels)+"@"+Day(p.Time).Format(time.RFC3339)] = true
+	}
+	end := sort.Search(len(points), func(i int) bool { return points[i].Time.After(to) })
+	points = points[:end]
+	rollups, err := s.Query(RollupName(name, DailyResolution, "last"), from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range rollups {
+		if raw[labelKey(p.Labels)+"@"+p.Time.Format(time.RFC3339)] {
+			continue
+		}
+		p.Name = name
+		points = append(points, p)
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	return points, nil
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCompact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfarmer-rollup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDelete(t, s)
+	if _, err := s.Delete("test.deleted", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a point every 30 minutes for 60 days, compacted on monday the 4th of march
+	var (
+		start  = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+		now    = time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC)
+		points []Point
+	)
+	for at, i := start, 0; at.Before(start.AddDate(0, 0, 60)); at, i = at.Add(30*time.Minute), i+1 {
+		points = append(points, Point{Time: at, Name: "test.users", Value: float64(i), RunID: "test"})
+	}
+	if err := s.Record(points...); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Compact(s, DefaultRetention, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Metrics != 1 {
+		t.Fatalf("expected 1 metric got %v", result.Metrics)
+	}
+
+	// raw points are kept for 30 days
+	raw, err := s.Query("test.users", time.Time{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 28*48 || !raw[0].Time.Equal(now.AddDate(0, 0, -30)) {
+		t.Fatalf("expected 28 days of raw points from %v, got %v from %v", now.AddDate(0, 0, -30), len(raw), raw[0].Time)
+	}
+	if result.Deleted != len(points)-len(raw) {
+		t.Fatalf("expected %v points to be deleted got %v", len(points)-len(raw), result.Deleted)
+	}
+
+	checkRollup := func(r Resolution, aggregate string, at time.Time, expected float64) {
+		t.Helper()
+		points, err := s.Query(RollupName("test.users", r, aggregate), at, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(points) != 1 || points[0].Value != expected || points[0].RunID != RollupRunID {
+			t.Fatalf("expected %s %s of %v at %v, got %+v", r, aggregate, expected, at, points)
+		}
+	}
+	// the second day holds the points 48 to 95
+	day := start.AddDate(0, 0, 1)
+	checkRollup(HourlyResolution, "avg", day, 48.5)
+	checkRollup(DailyResolution, "min", day, 48)
+	checkRollup(DailyResolution, "max", day, 95)
+	checkRollup(DailyResolution, "avg", day, 71.5)
+	checkRollup(DailyResolution, "last", day, 95)
+	checkRollup(DailyResolution, "count", day, 48)
+	// the week of monday the 7th of january holds the points 288 to 623
+	checkRollup(WeeklyResolution, "avg", time.Date(2019, 1, 7, 0, 0, 0, 0, time.UTC), 455.5)
+	checkRollup(MonthlyResolution, "last", start, 31*48-1)
+	checkRollup(MonthlyResolution, "count", start, 31*48)
+	// march hasn't ended, so isn't rolled up
+	monthly, err := s.Query(RollupName("test.users", MonthlyResolution, "count"), start.AddDate(0, 1, 0), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(monthly) != 1 {
+		t.Fatalf("expected only february to be rolled up, got %+v", monthly)
+	}
+
+	// compacting again must recompute the same rollups from what remains
+	if _, err := Compact(s, DefaultRetention, now); err != nil {
+		t.Fatal(err)
+	}
+	checkRollup(DailyResolution, "avg", day, 71.5)
+	checkRollup(MonthlyResolution, "count", start, 31*48)
+
+	// a point recorded late for a period already rolled up is only picked up by rolling up again
+	late := time.Date(2019, 2, 20, 0, 0, 0, 0, time.UTC)
+	if err := s.Record(Point{Time: late.Add(12*time.Hour + 10*time.Minute), Name: "test.users", Value: 10000, RunID: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Compact(s, DefaultRetention, now); err != nil {
+		t.Fatal(err)
+	}
+	checkRollup(DailyResolution, "max", late, 50*48+47)
+	if _, err := RollUp(s, "test.users", late, now); err != nil {
+		t.Fatal(err)
+	}
+	checkRollup(DailyResolution, "max", late, 10000)
+	checkRollup(MonthlyResolution, "max", late.AddDate(0, 0, -19), 10000)
+
+	// deletions must persist once the store is closed
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	names, err := s.Names()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if name == "test.deleted" {
+			t.Fatal("expected deleted metric to stay deleted")
+		}
+	}
+
+	// history falls back to daily rollups once raw points are removed
+	history, err := History(s, "test.users", start, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daily := Daily(history)
+	if len(daily) != 60 || daily[1].Value != 95 || daily[1].Name != "test.users" {
+		t.Fatalf("expected 60 days of history, got %v", len(daily))
+	}
+	// rollups only stand in for the days without raw points
+	if len(history) != len(raw)+1+32 {
+		t.Fatalf("expected raw points and 32 days of rollups, got %v points", len(history))
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	retention, err := ParseRetention("raw=7d, hourly=forever, monthly=3650d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retention[RawResolution] != 7*24*time.Hour || retention[MonthlyResolution] != 3650*24*time.Hour {
+		t.Fatalf("unexpected retention %v", retention)
+	}
+	if _, ok := retention[HourlyResolution]; ok {
+		t.Fatal("expected hourly rollups to be kept forever")
+	}
+	for _, rules := range []string{"raw", "secondly=1d", "raw=soon", "raw=30m", "daily=7d"} {
+		if _, err := ParseRetention(rules); err == nil {
+			t.Fatalf("expected %q to fail", rules)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Package store persists the metrics collected by tfarmer,
+// so that we accumulate history across runs
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"time"
+)
+
+// Point is a single recorded metric value
+type Point struct {
+	// Time is when the value was observed
+	Time time.Time
+	// Name is the name of the metric, such as "users.registered"
+	Name string
+	// Labels further identify the value, such as the tier of a per tier metric
+	Labels map[string]string
+	// Value is the observed value
+	Value float64
+	// RunID identifies the tfarmer run which recorded the value
+	RunID string
+}
+
+// PeriodRunID is the run id of points holding the value of a whole day or
+// period, such as the revenue of each day. as points recorded with the same
+// name, labels, time and run id replace each other, recording a period
+// again replaces its value rather than adding another.
+const PeriodRunID = "period"
+
+// Store is used to persist and query metric history
+type Store interface {
+	// Record is used to persist the given points. a point with the same
+	// name, labels, time and run id as a recorded point replaces it, so
+	// recording the same points again is idempotent.
+	Record(points ...Point) error
+	// Query is used to retrieve all points of the named
+	// metric observed within [from, to], ordered by time
+	Query(name string, from, to time.Time) ([]Point, error)
+	// QueryRun is used to retrieve all points recorded by
+	// the given run, ordered by name and then by time
+	QueryRun(runID string) ([]Point, error)
+	// Last is used to retrieve the last point of each series of the named
+	// metric observed at or before the given time, ordered by time
+	Last(name string, at time.Time) ([]Point, error)
+	// Names returns the name of every recorded metric, in order
+	Names() ([]string, error)
+	// Delete is used to remove the points of the named metric observed
+	// before the given time, returning the number of points removed
+	Delete(name string, before time.Time) (int, error)
+	// Close is used to release any resources held by the store
+	Close() error
+}
+
+// Filter returns the points whose labels include each of the given labels
+func Filter(points []Point, labels map[string]string) []Point {
+	filtered := []Point{}
+	for _, p := range points {
+		matches := true
+		for k, v := range labels {
+			if p.Labels[k] != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// Latest is used to retrieve the last point of every metric with each set of
+// labels, observed at or before the given time. series with only daily
+// rollups left before that time are represented by their last daily rollup.
+func Latest(s Store, at time.Time) ([]Point, error) {
+	names, err := metricNames(s)
+	if err != nil {
+		return nil, err
+	}
+	var latest []Point
+	for _, name := range names {
+		raw, err := s.Last(name, at)
+		if err != nil {
+			return nil, err
+		}
+		rollups, err := s.Last(RollupName(name, DailyResolution, "last"), at)
+		if err != nil {
+			return nil, err
+		}
+		last := make(map[string]int)
+		for _, p := range raw {
+			last[labelKey(p.Labels)] = len(latest)
+			latest = append(latest, p)
+		}
+		for _, p := range rollups {
+			p.Name = name
+			// a rollup only stands in for the days without raw points
+			i, ok := last[labelKey(p.Labels)]
+			if !ok {
+				latest = append(latest, p)
+			} else if p.Time.After(Day(latest[i].Time)) {
+				latest[i] = p
+			}
+		}
+	}
+	return latest, nil
+}
+
+// Daily returns the last point recorded on each day, ordered by day and
+// timestamped with the start of the day in utc. points may be in any order.
+func Daily(points []Point) []Point {
+	days := make(map[int64]Point)
+	for _, p := range points {
+		start := Day(p.Time).Unix()
+		if last, ok := days[start]; ok && last.Time.After(p.Time) {
+			continue
+		}
+		days[start] = p
+	}
+	daily := make([]Point, 0, len(days))
+	for start, p := range days {
+		p.Time = time.Unix(start, 0).UTC()
+		daily = append(daily, p)
+	}
+	sort.Slice(daily, func(i, j int) bool { return daily[i].Time.Before(daily[j].Time) })
+	return daily
+}
+
+// DailyDeltas returns the day over day change in the last point of each
+// day, timestamped with the start of the later day. days without a point
+// are skipped, so a delta may cover more than one day.
+func DailyDeltas(points []Point) []Point {
+	return dailyDeltas(points, false)
+}
+
+// DailyIncreases returns the day over day increase in the last point of each
+// day, treating the metric as a counter. a counter going backwards means it
+// has been reset, in which case its new value is the increase since the reset.
+func DailyIncreases(points []Point) []Point {
+	return dailyDeltas(points, true)
+}
+
+func dailyDeltas(points []Point, counter bool) []Point {
+	daily := Daily(points)
+	deltas := []Point{}
+	for i := 1; i < len(daily); i++ {
+		delta := daily[i]
+		delta.Value -= daily[i-1].Value
+		if counter && delta.Value < 0 {
+			delta.Value = daily[i].Value
+		}
+		deltas = append(deltas, delta)
+	}
+	return deltas
+}
+
+// Day returns the start of the day of the given time, in utc
+func Day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// NewRunID is used to generate a random identifier for a tfarmer run
+func NewRunID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		// the time is unique enough if we can't read randomness
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+	return hex.EncodeToString(id)
+}
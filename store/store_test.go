@@ -0,0 +1,303 @@
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/config/v2"
+	"github.com/jinzhu/gorm"
+)
+
+func TestPostgres(t *testing.T) {
+	cfg, err := config.LoadConfig("../testenv/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewPostgres(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// migrating twice must be a no-op
+	if _, err := NewPostgres(db); err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+	testDelete(t, s)
+	db.Exec("DELETE FROM tfarmer_metrics WHERE name LIKE 'test.%'")
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfarmer-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a write interrupted part way through a point
+	segment := filepath.Join(dir, "000001.seg")
+	file, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"t":"2019-01-01T00:00:00Z","n":"test.us`)
+	file.Close()
+
+	// reopening must load the recorded points and discard the partial one
+	s, err = NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := s.Record(Point{Time: now, Name: "test.users", Value: 4}); err != nil {
+		t.Fatal(err)
+	}
+	points, err := s.Query("test.users", time.Time{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 4 || points[3].Value != 4 {
+		t.Fatalf("unexpected points after reopening %+v", points)
+	}
+
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "000002.compacted.seg" {
+		t.Fatalf("expected a single compacted segment, got %v files", len(files))
+	}
+	if err := s.Record(Point{Time: now.Add(time.Second), Name: "test.users", Value: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a segment left behind by an interrupted compaction must be ignored
+	if err := ioutil.WriteFile(segment, []byte(`{"t":"2019-01-01T00:00:00Z","n":"test.users","v":1}`+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	points, err = s.Query("test.users", time.Time{}, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 5 || points[4].Value != 5 {
+		t.Fatalf("unexpected points after compaction %+v", points)
+	}
+	if _, err := os.Stat(segment); !os.IsNotExist(err) {
+		t.Fatal("expected replaced segment to be removed")
+	}
+}
+
+func TestDaily(t *testing.T) {
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	daily := Daily([]Point{
+		{Time: day.Add(25 * time.Hour), Value: 3},
+		{Time: day.Add(2 * time.Hour), Value: 2},
+		{Time: day.Add(time.Hour), Value: 1},
+	})
+	if len(daily) != 2 {
+		t.Fatalf("expected 2 days got %v", len(daily))
+	}
+	if !daily[0].Time.Equal(day) || daily[0].Value != 2 {
+		t.Fatalf("unexpected first day %+v", daily[0])
+	}
+	if !daily[1].Time.Equal(day.AddDate(0, 0, 1)) || daily[1].Value != 3 {
+		t.Fatalf("unexpected second day %+v", daily[1])
+	}
+}
+
+func TestDailyDeltas(t *testing.T) {
+	day := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	points := []Point{
+		{Time: day, Value: 100},
+		{Time: day.Add(time.Hour), Value: 110},
+		{Time: day.AddDate(0, 0, 1), Value: 150},
+		// the counter was reset by a restart
+		{Time: day.AddDate(0, 0, 3), Value: 30},
+	}
+	deltas := DailyDeltas(points)
+	if len(deltas) != 2 || deltas[0].Value != 40 || deltas[1].Value != -120 {
+		t.Fatalf("unexpected deltas %+v", deltas)
+	}
+	if !deltas[1].Time.Equal(Day(day.AddDate(0, 0, 3))) {
+		t.Fatalf("expected the delta to be at the later day, got %v", deltas[1].Time)
+	}
+	increases := DailyIncreases(points)
+	if len(increases) != 2 || increases[0].Value != 40 || increases[1].Value != 30 {
+		t.Fatalf("unexpected increases %+v", increases)
+	}
+}
+
+func TestLatest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfarmer-latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := s.Record(
+		Point{Time: day, Name: "test.users", Value: 1},
+		Point{Time: day.Add(time.Hour), Name: "test.users", Value: 2},
+		Point{Time: day.Add(2 * time.Hour), Name: "test.users", Value: 3},
+		Point{Time: day, Name: "test.users", Value: 10, Labels: map[string]string{"tier": "paid"}},
+		Point{Time: day.Add(time.Hour), Name: "test.uploads", Value: 5},
+		// daily rollups stand in for raw points which have been removed
+		Point{Time: day, Name: RollupName("test.keys", DailyResolution, "last"), Value: 7, RunID: RollupRunID},
+		// a later rollup stands in for a day whose raw points have been removed
+		Point{Time: day.AddDate(0, 0, -2), Name: "test.views", Value: 1},
+		Point{Time: day.AddDate(0, 0, -1), Name: RollupName("test.views", DailyResolution, "last"), Value: 4, RunID: RollupRunID},
+		// but not for a day which still has raw points
+		Point{Time: day, Name: RollupName("test.users", DailyResolution, "last"), Value: 99, RunID: RollupRunID},
+	); err != nil {
+		t.Fatal(err)
+	}
+	latest, err := Latest(s, day.Add(90*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) != 5 {
+		t.Fatalf("expected 5 points got %+v", latest)
+	}
+	values := make(map[string]float64)
+	for _, p := range latest {
+		values[p.Name+p.Labels["tier"]] = p.Value
+	}
+	if values["test.users"] != 2 || values["test.userspaid"] != 10 || values["test.uploads"] != 5 || values["test.keys"] != 7 || values["test.views"] != 4 {
+		t.Fatalf("unexpected latest points %+v", latest)
+	}
+}
+
+// testStore exercises the behaviour shared by every store implementation
+func testStore(t *testing.T, s Store) {
+	runID := NewRunID()
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := s.Record(
+		Point{Time: now.Add(-2 * time.Hour), Name: "test.users", Value: 1, RunID: runID},
+		Point{Time: now.Add(-time.Hour), Name: "test.users", Value: 2, RunID: runID,
+			Labels: map[string]string{"tier": "free"}},
+		Point{Time: now, Name: "test.users", Value: 3, RunID: runID},
+		Point{Time: now, Name: "test.uploads", Value: 10, RunID: runID},
+	); err != nil {
+		t.Fatal(err)
+	}
+	points, err := s.Query("test.users", now.Add(-90*time.Minute), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points got %v", len(points))
+	}
+	if points[0].Value != 2 || points[0].Labels["tier"] != "free" || points[0].RunID != runID {
+		t.Fatalf("unexpected point %+v", points[0])
+	}
+	if !points[1].Time.Equal(now) || points[1].Value != 3 {
+		t.Fatalf("unexpected point %+v", points[1])
+	}
+	// recording a point again must replace it
+	if err := s.Record(Point{Time: now, Name: "test.users", Value: 4, RunID: runID}); err != nil {
+		t.Fatal(err)
+	}
+	points, err = s.Query("test.users", now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 1 || points[0].Value != 4 {
+		t.Fatalf("expected recorded point to be replaced, got %+v", points)
+	}
+	points, err = s.QueryRun(runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 4 || points[0].Name != "test.uploads" || points[3].Value != 4 {
+		t.Fatalf("unexpected points of run %+v", points)
+	}
+	points, err = s.Last("test.users", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 || points[0].Labels["tier"] != "free" || points[1].Value != 4 {
+		t.Fatalf("expected the last point of each series, got %+v", points)
+	}
+	points, err = s.Last("test.users", now.Add(-90*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 1 || points[0].Value != 1 {
+		t.Fatalf("expected the last point before the given time, got %+v", points)
+	}
+}
+
+// testDelete exercises listing and deleting metrics, shared by every store implementation
+func testDelete(t *testing.T, s Store) {
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := s.Record(
+		Point{Time: now.Add(-2 * time.Hour), Name: "test.deleted", Value: 1},
+		Point{Time: now.Add(-time.Hour), Name: "test.deleted", Value: 2},
+		Point{Time: now, Name: "test.deleted", Value: 3},
+	); err != nil {
+		t.Fatal(err)
+	}
+	names, err := s.Names()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, name := range names {
+		found = found || name == "test.deleted"
+	}
+	if !found {
+		t.Fatalf("expected test.deleted in %v", names)
+	}
+	deleted, err := s.Delete("test.deleted", now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected 1 point to be deleted got %v", deleted)
+	}
+	points, err := s.Query("test.deleted", time.Time{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 || points[0].Value != 2 {
+		t.Fatalf("unexpected points after deleting %+v", points)
+	}
+}
+
+func openDatabaseConnection(cfg *config.TemporalConfig) (*gorm.DB, error) {
+	dbConnURL := fmt.Sprintf("host=127.0.0.1 port=%s user=postgres dbname=temporal password=%s sslmode=disable",
+		cfg.Database.Port, cfg.Database.Password)
+
+	return gorm.Open("postgres", dbConnURL)
+}
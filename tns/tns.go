@@ -0,0 +1,105 @@
+package tns
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/tfarmer/stats"
+	"github.com/jinzhu/gorm"
+)
+
+// used to scrape temporal name service related data
+
+// Farmer is the tns farmer for Temporal
+type Farmer struct {
+	ZM *models.ZoneManager
+}
+
+// NewFarmer instantiates our tns farmer class
+func NewFarmer(db *gorm.DB) *Farmer {
+	return &Farmer{
+		ZM: models.NewZoneManager(db),
+	}
+}
+
+// PeriodCount is the number of zones created in a single period
+type PeriodCount struct {
+	Period time.Time
+	Count  int
+}
+
+// TotalZones is used to get the total number of zones
+func (f *Farmer) TotalZones() (int, error) {
+	var count int
+	if err := f.ZM.DB.Model(&models.Zone{}).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ZonesCreated is used to get the number of zones created in each period
+// since the given time. period may be one of "day", "week" or "month".
+func (f *Farmer) ZonesCreated(period string, since time.Time) ([]PeriodCount, error) {
+	switch period {
+	case "day", "week", "month":
+	default:
+		return nil, fmt.Errorf("unsupported period '%s'", period)
+	}
+	rows, err := f.ZM.DB.Model(&models.Zone{}).
+		Where("created_at >= ?", since).
+		Select("date_trunc('" + period + "', created_at) AS period, count(*)").
+		Group("period").
+		Order("period").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := []PeriodCount{}
+	for rows.Next() {
+		var c PeriodCount
+		if err := rows.Scan(&c.Period, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// RecordsPerZone is used to get the distribution of the number of records in each zone
+func (f *Farmer) RecordsPerZone() (*stats.Histogram, error) {
+	rows, err := f.ZM.DB.Model(&models.Zone{}).
+		Select("coalesce(array_length(record_names, 1), 0)").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	h := stats.NewHistogram(
+		[]float64{1, 2, 6, 21, 101},
+		[]string{"0", "1", "2-5", "6-20", "21-100", "100+"},
+	)
+	for rows.Next() {
+		var records int
+		if err := rows.Scan(&records); err != nil {
+			return nil, err
+		}
+		h.Add(float64(records))
+	}
+	return h, rows.Err()
+}
+
+// StaleZones is used to get the number of zones which haven't been touched at
+// all since the given time. a zone is touched whenever its ipfs hash changes or
+// one of its records is added or updated, so this doesn't tell whether its
+// published hash has changed, which isn't tracked.
+func (f *Farmer) StaleZones(since time.Time) (int, error) {
+	var count int
+	if err := f.ZM.DB.Model(&models.Zone{}).
+		Where("updated_at < ?", since).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,90 @@
+package tns
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/config/v2"
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	testCID = "QmS4ustL54uo8FzR9455qaxZwuMiUhyvMcX9Ba8nUH4uVv"
+)
+
+func TestMigration(t *testing.T) {
+	cfg, err := config.LoadConfig("../testenv/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Zone{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Record{}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTNS(t *testing.T) {
+	cfg, err := config.LoadConfig("../testenv/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmer := NewFarmer(db)
+	zone, err := farmer.ZM.NewZone("testuser1", "testzone", "managerkey", "zonekey", testCID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer farmer.ZM.DB.Unscoped().Delete(zone)
+	if zone, err = farmer.ZM.AddRecordForZone("testzone", "testrecord", "testuser1"); err != nil {
+		t.Fatal(err)
+	}
+	if total, err := farmer.TotalZones(); err != nil {
+		t.Fatal(err)
+	} else if total == 0 {
+		t.Fatal("failed to find zone")
+	}
+	created, err := farmer.ZonesCreated("day", time.Now().AddDate(0, 0, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) == 0 || created[len(created)-1].Count == 0 {
+		t.Fatal("failed to find created zone")
+	}
+	records, err := farmer.RecordsPerZone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records.Buckets[1].Count == 0 {
+		t.Fatal("failed to find zone with a single record")
+	}
+	// the zone was just updated, so it can't be stale
+	stale, err := farmer.StaleZones(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := farmer.StaleZones(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all <= stale {
+		t.Fatal("failed to distinguish recently updated zone")
+	}
+}
+
+func openDatabaseConnection(cfg *config.TemporalConfig) (*gorm.DB, error) {
+	dbConnURL := fmt.Sprintf("host=127.0.0.1 port=%s user=postgres dbname=temporal password=%s sslmode=disable",
+		cfg.Database.Port, cfg.Database.Password)
+
+	return gorm.Open("postgres", dbConnURL)
+}
@@ -0,0 +1,83 @@
+package upload
+
+import (
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/tfarmer/stats"
+	"github.com/c2h5oh/datasize"
+)
+
+// Concentration describes how uploads and stored bytes are spread across
+// accounts. It intentionally contains no user names, only aggregates.
+type Concentration struct {
+	// Users is the number of accounts with at least one upload
+	Users int
+	// TotalBytes is the cumulative size of all uploads
+	TotalBytes int
+	// UploadsPerUser is a histogram of the number of uploads per account
+	UploadsPerUser *stats.Histogram
+	// BytesPerUser is a histogram of the number of bytes stored per account
+	BytesPerUser *stats.Histogram
+	// Gini is the gini coefficient of bytes stored per account
+	Gini float64
+	// Top1PercentShare is the share of bytes stored by the top 1% of accounts
+	Top1PercentShare float64
+	// Top10PercentShare is the share of bytes stored by the top 10% of accounts
+	Top10PercentShare float64
+}
+
+// UploadConcentration is used to determine how dependent our storage
+// volume is on a small number of accounts
+func (f *Farmer) UploadConcentration() (*Concentration, error) {
+	rows, err := f.UM.DB.Model(&models.Upload{}).Select("user_name, hash").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var (
+		uploadsPerUser = make(map[string]int)
+		bytesPerUser   = make(map[string]int)
+	)
+	for rows.Next() {
+		var userName, hash string
+		if err := rows.Scan(&userName, &hash); err != nil {
+			return nil, err
+		}
+		size, err := f.ObjectSize(hash)
+		if err != nil {
+			return nil, err
+		}
+		uploadsPerUser[userName]++
+		bytesPerUser[userName] += size
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	c := &Concentration{
+		Users: len(uploadsPerUser),
+		UploadsPerUser: stats.NewHistogram(
+			[]float64{2, 11, 101, 1001},
+			[]string{"1", "2-10", "11-100", "101-1000", "1001+"},
+		),
+		BytesPerUser: stats.NewHistogram(
+			[]float64{
+				float64(datasize.MB.Bytes()),
+				float64(100 * datasize.MB.Bytes()),
+				float64(datasize.GB.Bytes()),
+				float64(10 * datasize.GB.Bytes()),
+				float64(100 * datasize.GB.Bytes()),
+			},
+			[]string{"<1MB", "1MB-100MB", "100MB-1GB", "1GB-10GB", "10GB-100GB", "100GB+"},
+		),
+	}
+	sizes := make([]float64, 0, len(bytesPerUser))
+	for userName, count := range uploadsPerUser {
+		c.UploadsPerUser.Add(float64(count))
+		c.BytesPerUser.Add(float64(bytesPerUser[userName]))
+		c.TotalBytes += bytesPerUser[userName]
+		sizes = append(sizes, float64(bytesPerUser[userName]))
+	}
+	c.Gini = stats.Gini(sizes)
+	c.Top1PercentShare = stats.TopShare(sizes, 0.01)
+	c.Top10PercentShare = stats.TopShare(sizes, 0.10)
+	return c, nil
+}
@@ -0,0 +1,70 @@
+package upload
+
+import (
+	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/tfarmer/stats"
+)
+
+// Replication describes how often the same content is referenced by
+// multiple accounts and networks. It intentionally contains no hashes.
+type Replication struct {
+	// CIDs is the number of distinct content hashes
+	CIDs int
+	// SharedCIDs is the number of content hashes referenced by more than one upload
+	SharedCIDs int
+	// UsersPerCID is a histogram of distinct accounts referencing each hash
+	UsersPerCID *stats.Histogram
+	// NetworksPerCID is a histogram of distinct networks referencing each hash
+	NetworksPerCID *stats.Histogram
+	// BytesSaved is the number of bytes we avoid storing because uploads
+	// of the same hash on the same network are only stored once
+	BytesSaved int
+}
+
+// ContentReplication is used to determine how often content is shared
+// between accounts and networks
+func (f *Farmer) ContentReplication() (*Replication, error) {
+	rows, err := f.UM.DB.Model(&models.Upload{}).
+		Select("hash, count(distinct user_name), count(distinct network_name), count(*)").
+		Group("hash").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	r := &Replication{
+		UsersPerCID: stats.NewHistogram(
+			[]float64{2, 3, 6, 11},
+			[]string{"1", "2", "3-5", "6-10", "11+"},
+		),
+		NetworksPerCID: stats.NewHistogram(
+			[]float64{2, 3},
+			[]string{"1", "2", "3+"},
+		),
+	}
+	for rows.Next() {
+		var (
+			hash                     string
+			users, networks, uploads int
+		)
+		if err := rows.Scan(&hash, &users, &networks, &uploads); err != nil {
+			return nil, err
+		}
+		r.CIDs++
+		r.UsersPerCID.Add(float64(users))
+		r.NetworksPerCID.Add(float64(networks))
+		if uploads > 1 {
+			r.SharedCIDs++
+		}
+		// each network stores the content once, regardless
+		// of how many uploads reference it on that network
+		if duplicates := uploads - networks; duplicates > 0 {
+			size, err := f.ObjectSize(hash)
+			if err != nil {
+				return nil, err
+			}
+			r.BytesSaved += duplicates * size
+		}
+	}
+	return r, rows.Err()
+}
@@ -11,13 +11,16 @@ import (
 type Farmer struct {
 	UM   *models.UploadManager
 	ipfs rtfs.Manager
+	// sizes caches the cumulative size of hashes we've already stat'd
+	sizes map[string]int
 }
 
 // NewFarmer is used to instantiate our upload farmer
 func NewFarmer(db *gorm.DB, ipfs rtfs.Manager) *Farmer {
 	return &Farmer{
-		UM:   models.NewUploadManager(db),
-		ipfs: ipfs,
+		UM:    models.NewUploadManager(db),
+		ipfs:  ipfs,
+		sizes: make(map[string]int),
 	}
 }
 
@@ -93,3 +96,17 @@ func (f *Farmer) NumberOfUploads() (int, error) {
 	}
 	return len(uploads), nil
 }
+
+// ObjectSize is used to get the cumulative size of a hash, only
+// querying the ipfs node the first time a hash is seen
+func (f *Farmer) ObjectSize(hash string) (int, error) {
+	if size, ok := f.sizes[hash]; ok {
+		return size, nil
+	}
+	stats, err := f.ipfs.Stat(hash)
+	if err != nil {
+		return 0, err
+	}
+	f.sizes[hash] = stats.CumulativeSize
+	return stats.CumulativeSize, nil
+}
@@ -105,6 +105,16 @@ func TestUpload(t *testing.T) {
 	if size != expectedUniqueSize {
 		t.Fatal("Failed to calculate correct unique average size")
 	}
+	replication, err := farmer.ContentReplication()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replication.SharedCIDs == 0 {
+		t.Fatal("failed to find shared content")
+	}
+	if replication.UsersPerCID.Total() != replication.CIDs {
+		t.Fatal("accounts per content histogram should count every hash once")
+	}
 	if num, err := farmer.NumberOfUploads(); err != nil {
 		t.Fatal(err)
 	} else if num == 0 {
@@ -112,6 +122,49 @@ func TestUpload(t *testing.T) {
 	}
 }
 
+func TestUploadConcentration(t *testing.T) {
+	cfg, err := config.LoadConfig("../testenv/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDatabaseConnection(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ipfs, err := rtfs.NewManager(
+		cfg.IPFS.APIConnection.Host+":"+cfg.IPFS.APIConnection.Port,
+		"", 60*time.Minute,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmer := NewFarmer(db, ipfs)
+	upload1, err := farmer.UM.NewUpload(
+		testCID, "file", models.UploadOptions{
+			NetworkName:      "public",
+			Username:         "testuser1",
+			HoldTimeInMonths: 5,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer farmer.UM.DB.Unscoped().Delete(upload1)
+	c, err := farmer.UploadConcentration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Users == 0 || c.TotalBytes == 0 {
+		t.Fatal("failed to find uploading accounts")
+	}
+	if c.UploadsPerUser.Total() != c.Users || c.BytesPerUser.Total() != c.Users {
+		t.Fatal("histograms should count every account once")
+	}
+	if c.Top10PercentShare < c.Top1PercentShare {
+		t.Fatal("top 10% share can't be smaller than top 1% share")
+	}
+}
+
 func openDatabaseConnection(cfg *config.TemporalConfig) (*gorm.DB, error) {
 	dbConnURL := fmt.Sprintf("host=127.0.0.1 port=%s user=postgres dbname=temporal password=%s sslmode=disable",
 		cfg.Database.Port, cfg.Database.Password)
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/RTradeLtd/database/v2/models"
+	"github.com/RTradeLtd/tfarmer/asof"
 	"github.com/jinzhu/gorm"
 )
 
@@ -71,8 +72,8 @@ func (f *Farmer) PaidUsers() ([]models.User, error) {
 //  and is simply a login-in based metrics
 func (f *Farmer) ActiveUsers24Hours() ([]models.User, error) {
 	users := []models.User{}
-	tt := time.Now().Add(time.Hour * -24)
-	if err := f.UM.DB.Where("updated_at > ?", tt).Find(&users).Error; err != nil {
+	now := asof.Now(f.UM.DB)
+	if err := f.UM.DB.Where("updated_at > ? AND updated_at <= ?", now.Add(time.Hour*-24), now).Find(&users).Error; err != nil {
 		return nil, err
 	}
 	return users, nil
@@ -83,8 +84,8 @@ func (f *Farmer) ActiveUsers24Hours() ([]models.User, error) {
 // pubsub, key management, ipns, and upload usage
 func (f *Farmer) ActiveUsage24Hours() ([]models.User, error) {
 	usages := []models.Usage{}
-	tt := time.Now().Add(time.Hour * -24)
-	if err := f.US.DB.Where("updated_at > ?", tt).Find(&usages).Error; err != nil {
+	now := asof.Now(f.US.DB)
+	if err := f.US.DB.Where("updated_at > ? AND updated_at <= ?", now.Add(time.Hour*-24), now).Find(&usages).Error; err != nil {
 		return nil, err
 	}
 	users := []models.User{}